@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+var accountDeletionCollection *mongo.Collection = database.OpenCollection(database.Client, "account-deletion-collection")
+
+// pendingDeletionFilter matches the deletion of a user that has not finished or been cancelled.
+func pendingDeletionFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{
+		"userId": userID,
+		"status": bson.M{"$in": []string{models.AccountDeletionScheduled, models.AccountDeletionRunning}},
+	}
+}
+
+func DeleteAccount(c *gin.Context) {
+	type DeletionRequest struct {
+		Password string `json:"password"`
+	}
+	var request DeletionRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal := helper.GetPrincipal(c)
+	user, err := helper.GetUserById(principal.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !reauthenticate(c, user, request.Password) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := accountDeletionCollection.CountDocuments(ctx, pendingDeletionFilter(user.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this account is already scheduled for deletion"})
+		return
+	}
+
+	now := time.Now()
+	deletion := models.AccountDeletion{
+		ID:             primitive.NewObjectID(),
+		UserID:         user.ID,
+		Email:          user.Email,
+		Name:           user.Name,
+		Status:         models.AccountDeletionScheduled,
+		CompletedSteps: []string{},
+		RequestedAt:    now,
+		ScheduledFor:   now.Add(helper.GetEnvDuration("ACCOUNT_DELETION_GRACE", 14*24*time.Hour)),
+	}
+	if _, err := accountDeletionCollection.InsertOne(ctx, deletion); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the account stays usable during the grace period, but only from here
+	if err := helper.RevokeSessions(user.ID, bson.M{"_id": bson.M{"$ne": principal.SessionID}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := helper.RenderEmail("Your account will be deleted", user.Name, []string{
+		"You asked us to delete your SocialHive account. It will be deleted for good on " + deletion.ScheduledFor.UTC().Format(time.RFC1123) + ", together with your posts, comments, likes, messages and images.",
+		"Changed your mind? Log in and cancel the deletion before then.",
+	}, "", "")
+	if err := helper.QueueEmail(user.Email, "Your SocialHive account will be deleted", body); err != nil {
+		log.Println("failed to queue deletion notice:", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"data": deletion})
+}
+
+func GetAccountDeletion(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var deletion models.AccountDeletion
+	err := accountDeletionCollection.FindOne(ctx, pendingDeletionFilter(helper.GetPrincipal(c).ID)).Decode(&deletion)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this account is not scheduled for deletion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": deletion})
+}
+
+func CancelAccountDeletion(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// once the worker has started there is nothing left to restore
+	filter := bson.M{"userId": helper.GetPrincipal(c).ID, "status": models.AccountDeletionScheduled}
+	result, err := accountDeletionCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"status": models.AccountDeletionCancelled}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this account is not scheduled for deletion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account deletion cancelled"})
+}
+
+// GetAccountDeletions lets admins follow deletions, in particular ones stuck on a failing step.
+func GetAccountDeletions(c *gin.Context) {
+	page, limit, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.M{"scheduledFor": -1}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+	cursor, err := accountDeletionCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deletions := []models.AccountDeletion{}
+	if err := cursor.All(ctx, &deletions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": deletions, "page": page})
+}
@@ -0,0 +1,447 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"socialhive/database"
+	"socialhive/dto"
+	"socialhive/helper"
+	"socialhive/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paginate reads the page and limit query parameters, answering 400 when they are out of range.
+func paginate(c *gin.Context) (int64, int64, bool) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive number"})
+		return 0, 0, false
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit <= 0 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
+
+func ListUsers(c *gin.Context) {
+	page, limit, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		prefix := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(q), Options: "i"}
+		or := []bson.M{{"name": prefix}, {"email": prefix}}
+		if id, err := primitive.ObjectIDFromHex(q); err == nil {
+			or = append(or, bson.M{"_id": id})
+		}
+		filter["$or"] = or
+	}
+	if status := c.Query("status"); status == models.UserStatusActive {
+		filter["status"] = bson.M{"$in": []string{"", models.UserStatusActive}}
+	} else if status != "" {
+		filter["status"] = status
+	}
+	if role := c.Query("role"); role != "" {
+		filter["roles"] = role
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := userCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"createdAt": -1}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+	cursor, err := userCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewAdminUsers(users), "total": total, "page": page})
+}
+
+func GetUserForAdmin(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := helper.GetUserById(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewAdminUser(user)})
+}
+
+// moderationTarget loads the user an action is aimed at. Nobody may act on themselves, and only
+// admins may act on moderators and other admins.
+func moderationTarget(c *gin.Context) (models.User, bool) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+
+	user, err := helper.GetUserById(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+
+	if user.Status == models.UserStatusDeleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this account is being deleted"})
+		return models.User{}, false
+	}
+
+	principal := helper.GetPrincipal(c)
+	if user.ID == principal.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot do this to your own account"})
+		return models.User{}, false
+	}
+	if helper.IsStaff(user) && !principal.HasRole(helper.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only admins can act on staff accounts"})
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// recordAudit stores an admin action. The action already happened, so a failure is only logged.
+func recordAudit(c *gin.Context, action string, targetType string, targetID primitive.ObjectID, details bson.M) {
+	if err := helper.RecordAudit(c, action, targetType, targetID, details); err != nil {
+		log.Println("failed to record audit log:", err)
+	}
+}
+
+// setUserStatus changes the status of an account and ends its sessions so it takes effect at once.
+func setUserStatus(c *gin.Context, user models.User, status string, reason string, until time.Time) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"status":         status,
+		"statusReason":   reason,
+		"suspendedUntil": until,
+	}}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if status != models.UserStatusActive {
+		if err := helper.RevokeSessions(user.ID, nil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+	}
+	return true
+}
+
+func SuspendUser(c *gin.Context) {
+	type Suspension struct {
+		Reason string    `json:"reason" validate:"required"`
+		Until  time.Time `json:"until"`
+	}
+	var request Suspension
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !request.Until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a suspension must end in the future"})
+		return
+	}
+
+	user, ok := moderationTarget(c)
+	if !ok {
+		return
+	}
+	if user.Status == models.UserStatusBanned {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this account is banned"})
+		return
+	}
+
+	if !setUserStatus(c, user, models.UserStatusSuspended, request.Reason, request.Until) {
+		return
+	}
+	recordAudit(c, "user.suspend", "user", user.ID, bson.M{"reason": request.Reason, "until": request.Until})
+
+	c.JSON(http.StatusOK, gin.H{"message": "account suspended"})
+}
+
+func BanUser(c *gin.Context) {
+	type Ban struct {
+		Reason string `json:"reason" validate:"required"`
+	}
+	var request Ban
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := moderationTarget(c)
+	if !ok {
+		return
+	}
+
+	if !setUserStatus(c, user, models.UserStatusBanned, request.Reason, time.Time{}) {
+		return
+	}
+	recordAudit(c, "user.ban", "user", user.ID, bson.M{"reason": request.Reason})
+
+	c.JSON(http.StatusOK, gin.H{"message": "account banned"})
+}
+
+func ReinstateUser(c *gin.Context) {
+	user, ok := moderationTarget(c)
+	if !ok {
+		return
+	}
+
+	// lifting a ban is an admin decision, a moderator can only end suspensions
+	if user.Status == models.UserStatusBanned && !helper.GetPrincipal(c).HasRole(helper.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only admins can lift a ban"})
+		return
+	}
+
+	if !setUserStatus(c, user, models.UserStatusActive, "", time.Time{}) {
+		return
+	}
+	recordAudit(c, "user.reinstate", "user", user.ID, bson.M{"previousStatus": user.Status})
+
+	c.JSON(http.StatusOK, gin.H{"message": "account reinstated"})
+}
+
+func ForceLogoutUser(c *gin.Context) {
+	user, ok := moderationTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := helper.RevokeSessions(user.ID, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, "user.logout", "user", user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "all sessions of the account were ended"})
+}
+
+func SetUserRoles(c *gin.Context) {
+	type RoleChange struct {
+		Roles []string `json:"roles"`
+	}
+	var request RoleChange
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles := []string{}
+	for _, role := range request.Roles {
+		if !slices.Contains(helper.Roles, role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role " + role})
+			return
+		}
+		if role != helper.RoleUser && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	// the caller's own account is refused, an admin demoting themselves could leave nobody to undo it
+	user, ok := moderationTarget(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"roles": roles}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, "user.roles", "user", user.ID, bson.M{"from": user.Roles, "to": roles})
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"roles": helper.UserRoles(models.User{Roles: roles})}})
+}
+
+func UnlockUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := helper.GetUserById(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := helper.ClearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordAudit(c, "user.unlock", "user", user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "account unlocked"})
+}
+
+func AdminDeletePost(c *gin.Context) {
+	postID, err := primitive.ObjectIDFromHex(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var post models.Post
+	if err := postCollection.FindOneAndDelete(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
+	}
+
+	for _, image := range post.Images {
+		imageID, err := primitive.ObjectIDFromHex(image)
+		if err != nil {
+			continue
+		}
+		if err := database.GridFSBucket.Delete(imageID); err != nil {
+			log.Println("failed to delete image of removed post:", err)
+		}
+	}
+	recordAudit(c, "post.delete", "post", post.ID, bson.M{"uploader": post.Uploader, "text": post.Text})
+
+	c.JSON(http.StatusOK, gin.H{"message": "post deleted"})
+}
+
+func AdminDeleteComment(c *gin.Context) {
+	postID, err := primitive.ObjectIDFromHex(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	commentID, err := primitive.ObjectIDFromHex(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the projection keeps the removed comment for the audit log
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"comments": bson.M{"$elemMatch": bson.M{"_id": commentID}}})
+	filter := bson.M{"_id": postID, "comments._id": commentID}
+	var post models.Post
+	err = postCollection.FindOneAndUpdate(ctx, filter, bson.M{"$pull": bson.M{"comments": bson.M{"_id": commentID}}}, opts).Decode(&post)
+	if err != nil || len(post.Comments) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return
+	}
+	comment := post.Comments[0]
+	recordAudit(c, "comment.delete", "comment", comment.ID, bson.M{"post": postID, "commenter": comment.CommenterID, "text": comment.CommentText})
+
+	c.JSON(http.StatusOK, gin.H{"message": "comment deleted"})
+}
+
+func AdminDeleteMessage(c *gin.Context) {
+	messageID, err := primitive.ObjectIDFromHex(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var message models.Message
+	if err := msgCollection.FindOneAndDelete(ctx, bson.M{"_id": messageID}).Decode(&message); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return
+	}
+	recordAudit(c, "message.delete", "message", message.ID, bson.M{"sender": message.Sender.ID, "recipient": message.Recipient.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "message deleted"})
+}
+
+func GetAuditLog(c *gin.Context) {
+	page, limit, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{}
+	for _, field := range []string{"actorId", "targetId"} {
+		if value := c.Query(field); value != "" {
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			filter[field] = id
+		}
+	}
+	if action := c.Query("action"); action != "" {
+		filter["action"] = action
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.M{"createdAt": -1}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+	auditLogCollection := database.OpenCollection(database.Client, "audit-log-collection")
+	cursor, err := auditLogCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	entries := []models.AuditLog{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries, "page": page})
+}
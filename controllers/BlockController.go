@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"socialhive/dto"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+func BlockUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal := helper.GetPrincipal(c)
+	if userID == principal.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot block yourself"})
+		return
+	}
+	if _, err := helper.GetUserById(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// a block ends the follows in both directions and whatever requests are still pending
+	update := bson.M{
+		"$addToSet": bson.M{"blocked": userID},
+		"$pull": bson.M{
+			"followers":        userID,
+			"following":        userID,
+			"requestsReceived": bson.M{"from": userID},
+			"requestsSent":     bson.M{"to": userID},
+		},
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": principal.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	update = bson.M{"$pull": bson.M{
+		"followers":        principal.ID,
+		"following":        principal.ID,
+		"requestsReceived": bson.M{"from": principal.ID},
+		"requestsSent":     bson.M{"to": principal.ID},
+	}}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": userID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user blocked successfully"})
+}
+
+func UnblockUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the follows a block ended are not restored
+	update := bson.M{"$pull": bson.M{"blocked": userID}}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": helper.GetPrincipal(c).ID}, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this user is not blocked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unblocked successfully"})
+}
+
+func GetBlockedUsers(c *gin.Context) {
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users := []models.User{}
+	if len(user.Blocked) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := userCollection.Find(ctx, bson.M{"_id": bson.M{"$in": user.Blocked}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := cursor.All(ctx, &users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	views := []dto.PublicUser{}
+	for _, blockedUser := range users {
+		views = append(views, dto.NewPublicUser(blockedUser))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": views})
+}
@@ -10,7 +10,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"log"
 	"net/http"
+	"slices"
 	"socialhive/database"
+	"socialhive/dto"
 	"socialhive/helper"
 	"socialhive/models"
 	"sync"
@@ -18,15 +20,15 @@ import (
 )
 
 type Server struct {
-	conns map[*websocket.Conn]bool
-	email map[string]*websocket.Conn
+	conns map[*websocket.Conn]primitive.ObjectID
+	users map[primitive.ObjectID]*websocket.Conn
 	mut   sync.Mutex
 }
 
 func NewServer() *Server {
 	return &Server{
-		conns: make(map[*websocket.Conn]bool),
-		email: make(map[string]*websocket.Conn),
+		conns: make(map[*websocket.Conn]primitive.ObjectID),
+		users: make(map[primitive.ObjectID]*websocket.Conn),
 	}
 }
 
@@ -38,9 +40,11 @@ var upgrader = websocket.Upgrader{
 
 func (s *Server) HandleWS(c *gin.Context) {
 
-	email := helper.ExtractEmail(c)
+	principal := helper.GetPrincipal(c)
 
-	_, exists := s.email[email]
+	s.mut.Lock()
+	_, exists := s.users[principal.ID]
+	s.mut.Unlock()
 	//if exists {
 	//	c.JSON(400, gin.H{"message": "connection already made"})
 	//	return
@@ -62,11 +66,11 @@ func (s *Server) HandleWS(c *gin.Context) {
 	fmt.Println("New incoming connection from client:", conn.RemoteAddr())
 
 	s.mut.Lock()
-	s.conns[conn] = true
-	s.email[email] = conn
+	s.conns[conn] = principal.ID
+	s.users[principal.ID] = conn
 	s.mut.Unlock()
 	userCollection := database.OpenCollection(database.Client, "user-collection")
-	filter := bson.M{"email": email}
+	filter := bson.M{"_id": principal.ID}
 	update := bson.M{"$set": bson.M{
 		"lastActive": time.Time{},
 		"isActive":   true,
@@ -81,19 +85,20 @@ func (s *Server) HandleWS(c *gin.Context) {
 	cancel()
 
 	if !exists {
-		s.sendOnlineSignal(conn, email)
+		s.sendOnlineSignal(presenceUser(principal.ID))
 	}
 
-	s.readLoop(conn, email, c)
+	s.readLoop(conn, c, principal.ID)
 
 	s.mut.Lock()
 	delete(s.conns, conn)
-	delete(s.email, email)
+	delete(s.users, principal.ID)
 	s.mut.Unlock()
 
-	s.sendOfflineSignal(conn, email)
+	// the handle may have changed while the connection was open
+	s.sendOfflineSignal(presenceUser(principal.ID))
 
-	filter = bson.M{"email": email}
+	filter = bson.M{"_id": principal.ID}
 	update = bson.M{"$set": bson.M{
 		"lastActive": time.Now(),
 		"isActive":   false,
@@ -108,55 +113,91 @@ func (s *Server) HandleWS(c *gin.Context) {
 	}
 }
 
-func (s *Server) sendOnlineSignal(senderConn *websocket.Conn, senderEmail string) {
+// presenceUser loads who online and offline signals are about. Signals go to every connected
+// user, so they carry the public view and never an email.
+func presenceUser(userID primitive.ObjectID) models.User {
+	user, err := helper.GetUserById(userID)
+	if err != nil {
+		return models.User{ID: userID}
+	}
+	return user
+}
+
+func (s *Server) sendOnlineSignal(sender models.User) {
 	type MsgToBroadcast struct {
-		Action string `json:"action"`
-		Email  string `json:"message_content"`
+		Action string         `json:"action"`
+		User   dto.PublicUser `json:"message_content"`
 	}
 
 	msgToBroadcast := MsgToBroadcast{
 		Action: "online",
-		Email:  senderEmail,
+		User:   dto.NewPublicUser(sender),
 	}
 
 	msgJson, _ := json.Marshal(msgToBroadcast)
+	senderID := sender.ID
+
+	// those who muted the sender are not told when they come and go
+	mutedBy, err := helper.MutedByUserIDs(senderID)
+	if err != nil {
+		log.Println("failed to load mutes:", err)
+	}
+	// nor is anyone on either side of a block, and nobody is told if the blocks cannot be checked
+	blocked, err := helper.BlockedUserIDs(senderID)
+	if err != nil {
+		log.Println("failed to load blocks:", err)
+		return
+	}
 
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
-	for email := range s.email {
-		if email != senderEmail {
-			go s.sendMessage(s.email[email], string(msgJson))
+	for userID, conn := range s.users {
+		if userID != senderID && !slices.Contains(mutedBy, userID) && !slices.Contains(blocked, userID) {
+			go s.sendMessage(conn, string(msgJson))
 		}
 	}
 
 }
 
-func (s *Server) sendOfflineSignal(senderConn *websocket.Conn, senderEmail string) {
+func (s *Server) sendOfflineSignal(sender models.User) {
 	type MsgToBroadcast struct {
-		Action string `json:"action"`
-		Email  string `json:"message_content"`
+		Action string         `json:"action"`
+		User   dto.PublicUser `json:"message_content"`
 	}
 
 	msgToBroadcast := MsgToBroadcast{
 		Action: "offline",
-		Email:  senderEmail,
+		User:   dto.NewPublicUser(sender),
 	}
 
 	msgJson, _ := json.Marshal(msgToBroadcast)
+	senderID := sender.ID
+
+	// those who muted the sender are not told when they come and go
+	mutedBy, err := helper.MutedByUserIDs(senderID)
+	if err != nil {
+		log.Println("failed to load mutes:", err)
+	}
+	// nor is anyone on either side of a block, and nobody is told if the blocks cannot be checked
+	blocked, err := helper.BlockedUserIDs(senderID)
+	if err != nil {
+		log.Println("failed to load blocks:", err)
+		return
+	}
 
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
-	for email := range s.email {
-		if email != senderEmail {
-			go s.sendMessage(s.email[email], string(msgJson))
+	for userID, conn := range s.users {
+		if userID != senderID && !slices.Contains(mutedBy, userID) && !slices.Contains(blocked, userID) {
+			go s.sendMessage(conn, string(msgJson))
 		}
 	}
 
 }
 
-func (s *Server) readLoop(conn *websocket.Conn, senderEmail string, c *gin.Context) {
+func (s *Server) readLoop(conn *websocket.Conn, c *gin.Context, senderID primitive.ObjectID) {
 	for {
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
@@ -167,11 +208,11 @@ func (s *Server) readLoop(conn *websocket.Conn, senderEmail string, c *gin.Conte
 			fmt.Println("Read error:", err)
 			break
 		}
-		s.broadcast(conn, c, msg, senderEmail)
+		s.broadcast(conn, c, msg, senderID)
 	}
 }
 
-func (s *Server) broadcast(senderConnection *websocket.Conn, c *gin.Context, msg []byte, senderEmail string) {
+func (s *Server) broadcast(senderConnection *websocket.Conn, c *gin.Context, msg []byte, senderID primitive.ObjectID) {
 	type Message struct {
 		Action string `json:"action"`
 		To     string `json:"to"`
@@ -179,7 +220,8 @@ func (s *Server) broadcast(senderConnection *websocket.Conn, c *gin.Context, msg
 	}
 	var parsedMessage Message
 	err := json.Unmarshal(msg, &parsedMessage)
-	email := parsedMessage.To
+	// the recipient is given by email or by handle
+	address := parsedMessage.To
 
 	if err != nil {
 		fmt.Println("Error parsing JSON:", err)
@@ -189,12 +231,29 @@ func (s *Server) broadcast(senderConnection *websocket.Conn, c *gin.Context, msg
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
-	// extract recipient connection
+	sender, _ := helper.GetUserById(senderID)
 
-	recipientConnection, recipientExists := s.email[email]
+	// the upgrade is refused to restricted accounts, this ends connections opened before
+	if helper.AccountRestricted(sender) {
+		closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "account restricted")
+		senderConnection.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+		return
+	}
+
+	recipient, err := helper.GetUserByAddress(address)
+
+	// nothing is delivered between users when either of them blocked the other
+	if err == nil && parsedMessage.Action == "send" {
+		if blocked, blockErr := helper.IsBlocked(sender.ID, recipient.ID); blockErr != nil || blocked {
+			go s.sendMessage(senderConnection, "you cannot message this user")
+			return
+		}
+	}
+
+	// extract recipient connection
 
-	sender, _ := helper.GetUserByEmail(senderEmail)
-	recipient, err := helper.GetUserByEmail(email)
+	recipientConnection, recipientExists := s.users[recipient.ID]
+	recipientExists = recipientExists && err == nil
 	msgContent := parsedMessage.Msg
 
 	var newMsg models.Message
@@ -202,8 +261,8 @@ func (s *Server) broadcast(senderConnection *websocket.Conn, c *gin.Context, msg
 	if parsedMessage.Action == "send" {
 		newMsg = models.Message{
 			ID:        primitive.NewObjectID(),
-			Sender:    sender,
-			Recipient: recipient,
+			Sender:    helper.NewMessageParticipant(sender),
+			Recipient: helper.NewMessageParticipant(recipient),
 			Content:   string(msgContent),
 			Timestamp: time.Now(),
 			Status:    "sent",
@@ -243,13 +302,13 @@ func (s *Server) broadcast(senderConnection *websocket.Conn, c *gin.Context, msg
 	}
 
 	type MsgToBroadCast struct {
-		Action          string         `json:"action"`
-		MesssageContent models.Message `json:"message_content"`
+		Action          string      `json:"action"`
+		MesssageContent dto.Message `json:"message_content"`
 	}
 
 	msgToBroadCast := MsgToBroadCast{
 		Action:          parsedMessage.Action,
-		MesssageContent: newMsg,
+		MesssageContent: dto.NewMessage(newMsg),
 	}
 
 	// Convert struct to JSON string
@@ -258,7 +317,8 @@ func (s *Server) broadcast(senderConnection *websocket.Conn, c *gin.Context, msg
 		fmt.Println("Error marshalling message:", err)
 		return
 	}
-	if recipientExists {
+	// a message from someone the recipient muted is kept for their inbox but not pushed to them
+	if recipientExists && !slices.Contains(helper.MutedUserIDs(recipient), sender.ID) {
 		go s.sendMessage(recipientConnection, string(msgJson))
 	}
 
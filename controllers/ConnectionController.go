@@ -8,6 +8,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"net/http"
+	"slices"
+	"socialhive/dto"
 	"socialhive/helper"
 	"socialhive/models"
 	"time"
@@ -30,9 +32,7 @@ func SendFollowRequest(c *gin.Context) {
 	}
 
 	// check if sender is logged-in user
-	loggedInUserEmail := helper.ExtractEmail(c)
-
-	user, err := helper.GetUserByEmail(loggedInUserEmail)
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -43,6 +43,17 @@ func SendFollowRequest(c *gin.Context) {
 		return
 	}
 
+	// suspended and banned accounts cannot be followed, and neither can those who blocked the sender
+	receiver, err := helper.GetUserById(receiverID)
+	if err != nil || helper.AccountRestricted(receiver) || slices.Contains(receiver.Blocked, senderID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "receiver not found"})
+		return
+	}
+	if slices.Contains(user.Blocked, receiverID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unblock this user before following them"})
+		return
+	}
+
 	// check if already followed the receiver
 
 	filter := bson.M{
@@ -65,6 +76,16 @@ func SendFollowRequest(c *gin.Context) {
 		return
 	}
 
+	// public accounts take every follower without asking
+	if receiver.IsPublic {
+		if err := addFollow(ctx, senderID, receiverID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "user followed successfully"})
+		return
+	}
+
 	var followRequest models.FollowRequest
 
 	followRequest = models.FollowRequest{
@@ -96,6 +117,25 @@ func SendFollowRequest(c *gin.Context) {
 
 }
 
+// addFollow makes one user follow another straight away, settling any request still pending
+// between them.
+func addFollow(ctx context.Context, followerID primitive.ObjectID, followeeID primitive.ObjectID) error {
+	update := bson.M{
+		"$addToSet": bson.M{"followers": followerID},
+		"$pull":     bson.M{"requestsReceived": bson.M{"from": followerID}},
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": followeeID}, update); err != nil {
+		return err
+	}
+
+	update = bson.M{
+		"$addToSet": bson.M{"following": followeeID},
+		"$pull":     bson.M{"requestsSent": bson.M{"to": followeeID}},
+	}
+	_, err := userCollection.UpdateOne(ctx, bson.M{"_id": followerID}, update)
+	return err
+}
+
 func AcceptFollowRequest(c *gin.Context) {
 	requestIDHex := c.Param("request_id")
 	requestID, err := primitive.ObjectIDFromHex(requestIDHex)
@@ -104,8 +144,7 @@ func AcceptFollowRequest(c *gin.Context) {
 		return
 	}
 
-	loggedInUserEmail := helper.ExtractEmail(c)
-	user, err := helper.GetUserByEmail(loggedInUserEmail)
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -148,6 +187,17 @@ func AcceptFollowRequest(c *gin.Context) {
 
 	var followRequest models.FollowRequest = result.FollowRequests[0]
 
+	// the sender may have been blocked, suspended or banned since the request was made
+	sender, err := helper.GetUserById(followRequest.From)
+	if err != nil || helper.AccountRestricted(sender) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this request can no longer be accepted"})
+		return
+	}
+	if blocked, err := helper.IsBlocked(user.ID, sender.ID); err != nil || blocked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this request can no longer be accepted"})
+		return
+	}
+
 	filter = bson.M{"_id": user.ID}
 	update := bson.M{
 		"$pull": bson.M{
@@ -190,8 +240,7 @@ func DeleteFollowRequestBySender(c *gin.Context) {
 		return
 	}
 
-	loggedInUserEmail := helper.ExtractEmail(c)
-	user, err := helper.GetUserByEmail(loggedInUserEmail)
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -271,8 +320,7 @@ func DeleteFollowRequestByReceiver(c *gin.Context) {
 		return
 	}
 
-	loggedInUserEmail := helper.ExtractEmail(c)
-	user, err := helper.GetUserByEmail(loggedInUserEmail)
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -357,10 +405,26 @@ func GetAllFollowers(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if hiddenFromCaller(c, user) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !contentVisibleToCaller(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this account is private"})
+		return
+	}
+
+	// people on either side of a block with the caller are left out of the list
+	blocked, err := helper.BlockedUserIDs(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	filter := bson.M{
 		"_id": bson.M{
-			"$in": user.Followers,
+			"$nin": blocked,
+			"$in":  user.Followers,
 		},
 	}
 
@@ -379,7 +443,7 @@ func GetAllFollowers(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, dto.NewUsers(users, helper.GetPrincipal(c).ID))
 }
 
 func GetAllFollowing(c *gin.Context) {
@@ -395,10 +459,26 @@ func GetAllFollowing(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if hiddenFromCaller(c, user) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !contentVisibleToCaller(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this account is private"})
+		return
+	}
+
+	// people on either side of a block with the caller are left out of the list
+	blocked, err := helper.BlockedUserIDs(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	filter := bson.M{
 		"_id": bson.M{
-			"$in": user.Following,
+			"$nin": blocked,
+			"$in":  user.Following,
 		},
 	}
 
@@ -417,7 +497,7 @@ func GetAllFollowing(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, dto.NewUsers(users, helper.GetPrincipal(c).ID))
 }
 
 func UnFollow(c *gin.Context) {
@@ -428,12 +508,7 @@ func UnFollow(c *gin.Context) {
 		return
 	}
 
-	loggedInUserEmail := helper.ExtractEmail(c)
-	loggedInUser, err := helper.GetUserByEmail(loggedInUserEmail)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+	loggedInUser := helper.GetPrincipal(c)
 
 	filter := bson.M{"_id": userID}
 	update := bson.M{
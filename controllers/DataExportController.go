@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"net/http"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+// one archive a day is plenty, building one reads everything the user ever stored
+const dataExportCooldown = 24 * time.Hour
+
+var dataExportCollection *mongo.Collection = database.OpenCollection(database.Client, "data-export-collection")
+
+func RequestDataExport(c *gin.Context) {
+	userID := helper.GetPrincipal(c).ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"userId": userID,
+		"$or": []bson.M{
+			{"status": bson.M{"$in": []string{models.DataExportPending, models.DataExportBuilding}}},
+			{"status": models.DataExportReady, "createdAt": bson.M{"$gt": time.Now().Add(-dataExportCooldown)}},
+		},
+	}
+	count, err := dataExportCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "an export was requested recently, check your email for the download link"})
+		return
+	}
+
+	export := models.DataExport{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Status:    models.DataExportPending,
+		CreatedAt: time.Now(),
+	}
+	if _, err := dataExportCollection.InsertOne(ctx, export); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"data": export})
+}
+
+// findOwnDataExport loads an export of the caller, answering 404 for anyone else's.
+func findOwnDataExport(c *gin.Context) (models.DataExport, bool) {
+	exportID, err := primitive.ObjectIDFromHex(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.DataExport{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var export models.DataExport
+	filter := bson.M{"_id": exportID, "userId": helper.GetPrincipal(c).ID}
+	if err := dataExportCollection.FindOne(ctx, filter).Decode(&export); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+		return models.DataExport{}, false
+	}
+	return export, true
+}
+
+func GetDataExport(c *gin.Context) {
+	export, ok := findOwnDataExport(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": export})
+}
+
+func DownloadDataExport(c *gin.Context) {
+	export, ok := findOwnDataExport(c)
+	if !ok {
+		return
+	}
+
+	if export.Status != models.DataExportReady || !export.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this export is not available for download"})
+		return
+	}
+
+	file, err := database.ExportBucket.OpenDownloadStream(export.FileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this export is not available for download"})
+		return
+	}
+	defer file.Close()
+
+	filename := fmt.Sprintf("socialhive-export-%s.zip", export.CreatedAt.UTC().Format("2006-01-02"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Content-Length", fmt.Sprint(export.Size))
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, file)
+}
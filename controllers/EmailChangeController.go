@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/url"
+	"os"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"strings"
+	"time"
+)
+
+const (
+	emailChangeCodeTTL     = 15 * time.Minute
+	emailChangeRevertTTL   = 7 * 24 * time.Hour
+	emailChangeMaxAttempts = 5
+)
+
+var emailChangeCollection *mongo.Collection = database.OpenCollection(database.Client, "email-change-collection")
+
+func RequestEmailChange(c *gin.Context) {
+	type EmailChangeRequest struct {
+		NewEmail string `json:"newEmail" validate:"required,email"`
+		Password string `json:"password"`
+	}
+	var request EmailChangeRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.NewEmail = strings.TrimSpace(request.NewEmail)
+	if err := validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !reauthenticate(c, user, request.Password) {
+		return
+	}
+
+	if strings.EqualFold(request.NewEmail, user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this is already your email"})
+		return
+	}
+
+	emailInUse, err := helper.EmailInUse(request.NewEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if emailInUse {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This email is already in use"})
+		return
+	}
+
+	code, err := helper.GenerateCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// only the latest request can be confirmed
+	_, err = emailChangeCollection.DeleteMany(ctx, bson.M{"userId": user.ID, "status": models.EmailChangeStatusPending})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	emailChange := models.EmailChange{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  request.NewEmail,
+		CodeHash:  helper.HashToken(code),
+		Status:    models.EmailChangeStatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(emailChangeCodeTTL),
+	}
+
+	if _, err = emailChangeCollection.InsertOne(ctx, emailChange); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := helper.RenderEmail("Confirm your new email", user.Name, []string{
+		"Use the following code to confirm that you want to use this address for your SocialHive account. The code is valid for 15 minutes.",
+		code,
+		"If you did not ask for this change you can ignore this email.",
+	}, "", "")
+	if err := helper.QueueEmail(request.NewEmail, "Confirm your new email for SocialHive", body); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "verification code sent to the new email"})
+}
+
+func ConfirmEmailChange(c *gin.Context) {
+	type Code struct {
+		Code string `json:"code"`
+	}
+	var code Code
+	if err := c.ShouldBind(&code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"userId":    user.ID,
+		"status":    models.EmailChangeStatusPending,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	var emailChange models.EmailChange
+	if err := emailChangeCollection.FindOne(ctx, filter).Decode(&emailChange); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no pending email change"})
+		return
+	}
+
+	if emailChange.Attempts >= emailChangeMaxAttempts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many wrong codes, request a new one"})
+		return
+	}
+
+	if !helper.TokenMatchesHash(code.Code, emailChange.CodeHash) {
+		emailChangeCollection.UpdateOne(ctx, bson.M{"_id": emailChange.ID}, bson.M{"$inc": bson.M{"attempts": 1}})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wrong code"})
+		return
+	}
+
+	// the address may have been claimed while the code was in flight
+	emailInUse, err := helper.EmailInUse(emailChange.NewEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if emailInUse {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This email is already in use"})
+		return
+	}
+
+	if err := switchUserEmail(ctx, user.ID, emailChange.OldEmail, emailChange.NewEmail); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	revertToken, err := helper.GenerateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"status":          models.EmailChangeStatusCompleted,
+		"completedAt":     now,
+		"revertTokenHash": helper.HashToken(revertToken),
+		"revertExpiresAt": now.Add(emailChangeRevertTTL),
+	}}
+	if _, err := emailChangeCollection.UpdateOne(ctx, bson.M{"_id": emailChange.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := helper.RenderEmail("Your email was changed", user.Name, []string{
+		"The email address of your SocialHive account was changed to " + emailChange.NewEmail + ".",
+		"If you did not make this change, use the button below within 7 days to restore this address.",
+	}, "This wasn't me", os.Getenv("EMAIL_REVERT_URL")+"?token="+url.QueryEscape(revertToken))
+	if err := helper.QueueEmail(emailChange.OldEmail, "Your SocialHive email was changed", body); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"email": emailChange.NewEmail}})
+}
+
+// ShowEmailRevert serves the revert links sent before they pointed at the web app. Opening a link
+// changes nothing, it leads to EMAIL_REVERT_URL, a page of the web app that asks the owner to
+// confirm and then posts the token to /email/revert, so that mail scanners following the link
+// cannot revert a change.
+func ShowEmailRevert(c *gin.Context) {
+	c.Redirect(http.StatusFound, os.Getenv("EMAIL_REVERT_URL")+"?token="+url.QueryEscape(c.Param("token")))
+}
+
+func RevertEmailChange(c *gin.Context) {
+	type EmailRevertRequest struct {
+		Token string `json:"token"`
+	}
+	var request EmailRevertRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"revertTokenHash": helper.HashToken(request.Token),
+		"status":          models.EmailChangeStatusCompleted,
+		"revertExpiresAt": bson.M{"$gt": time.Now()},
+	}
+	var emailChange models.EmailChange
+	if err := emailChangeCollection.FindOne(ctx, filter).Decode(&emailChange); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired link"})
+		return
+	}
+
+	user, err := helper.GetUserById(emailChange.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// a later change would be undone by restoring this one, so only the most recent can be reverted
+	if user.Email != emailChange.NewEmail {
+		c.JSON(http.StatusConflict, gin.H{"error": "the email has been changed again since this link was sent"})
+		return
+	}
+
+	if err := switchUserEmail(ctx, user.ID, emailChange.NewEmail, emailChange.OldEmail); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the address being reverted is likely not the owner's, so it is released rather than reserved
+	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$pull": bson.M{"previousEmails": emailChange.NewEmail}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err = emailChangeCollection.UpdateOne(ctx, bson.M{"_id": emailChange.ID}, bson.M{"$set": bson.M{"status": models.EmailChangeStatusReverted}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// whoever made the change was logged in and may know the password, so every session ends and
+	// the owner has to choose a new password before anyone can log in again
+	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"passwordResetDue": true}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := helper.RevokeSessions(user.ID, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user.Email = emailChange.OldEmail
+	if err := sendPasswordReset(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"email": emailChange.OldEmail}})
+}
+
+// switchUserEmail changes the address of a user and of every record keyed on it.
+func switchUserEmail(ctx context.Context, userID primitive.ObjectID, fromEmail string, toEmail string) error {
+	update := bson.M{
+		"$set":      bson.M{"email": toEmail},
+		"$addToSet": bson.M{"previousEmails": fromEmail},
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": userID}, update); err != nil {
+		return err
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$pull": bson.M{"previousEmails": toEmail}}); err != nil {
+		return err
+	}
+
+	// messages embed copies of both users and are looked up by their email
+	_, err := msgCollection.UpdateMany(ctx, bson.M{"sender._id": userID}, bson.M{"$set": bson.M{"sender.email": toEmail}})
+	if err != nil {
+		return err
+	}
+	_, err = msgCollection.UpdateMany(ctx, bson.M{"recipient._id": userID}, bson.M{"$set": bson.M{"recipient.email": toEmail}})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"net/url"
+	"socialhive/dto"
+	"socialhive/helper"
+	"strings"
+	"time"
+)
+
+func ChangeHandle(c *gin.Context) {
+	type HandleChange struct {
+		Handle string `json:"handle"`
+	}
+	var request HandleChange
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	handle := strings.TrimPrefix(strings.TrimSpace(request.Handle), "@")
+
+	if err := helper.ValidateHandle(handle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	normalized := helper.NormalizeHandle(handle)
+
+	// renaming often would let people hop between handles to dodge blocks and confuse followers;
+	// changing only the case, or claiming a first handle, is always fine
+	if user.Handle != "" && user.HandleLower != normalized {
+		cooldown := helper.GetEnvDuration("HANDLE_CHANGE_COOLDOWN", 30*24*time.Hour)
+		if nextChange := user.HandleChangedAt.Add(cooldown); nextChange.After(time.Now()) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "you can change your handle again after " + nextChange.UTC().Format(time.RFC1123)})
+			return
+		}
+	}
+
+	handleInUse, err := helper.HandleInUse(handle, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if handleInUse {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this handle is already taken"})
+		return
+	}
+
+	set := bson.M{"handle": handle, "handleLower": normalized, "searchTerms": helper.SearchTerms(user.Name, handle)}
+	update := bson.M{"$set": set}
+	if user.HandleLower != "" && user.HandleLower != normalized {
+		// the old handle keeps redirecting here; taking back an old handle frees it from that list
+		set["handleChangedAt"] = time.Now()
+		update["$push"] = bson.M{"previousHandles": user.HandleLower}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = userCollection.FindOneAndUpdate(ctx, bson.M{"_id": user.ID}, update, opts).Decode(&user)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this handle is already taken"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$pull": bson.M{"previousHandles": normalized}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewSelfUser(user)})
+}
+
+func GetUserByHandle(c *gin.Context) {
+	user, moved, err := helper.GetUserByHandle(c.Param("handle"))
+	if err != nil || hiddenFromCaller(c, user) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// links to a handle the user renamed away from follow them to the new one
+	if moved {
+		c.Redirect(http.StatusMovedPermanently, "/u/"+url.PathEscape(user.Handle))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewUser(user, helper.GetPrincipal(c).ID)})
+}
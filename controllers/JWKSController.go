@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"socialhive/helper"
+)
+
+func GetJWKS(c *gin.Context) {
+	// verifiers refetch on an unknown kid, so a short cache is enough to pick up rotations
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": helper.PublicJWKS()})
+}
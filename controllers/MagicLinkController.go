@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/url"
+	"os"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"strings"
+	"time"
+)
+
+const (
+	magicLinkTTL         = 15 * time.Minute
+	magicLinkRateWindow  = 15 * time.Minute
+	magicLinkRateMaxSent = 3
+)
+
+var magicLinkCollection *mongo.Collection = database.OpenCollection(database.Client, "magic-link-collection")
+
+func RequestMagicLink(c *gin.Context) {
+	type MagicLinkRequest struct {
+		Email string `json:"email"`
+	}
+	var request MagicLinkRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := strings.TrimSpace(request.Email)
+
+	// the answer is the same whether or not the address has an account
+	response := gin.H{"data": "if this email belongs to an account, a login link has been sent"}
+
+	user, err := helper.GetUserByEmail(email)
+	if err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sent, err := magicLinkCollection.CountDocuments(ctx, bson.M{
+		"userId":    user.ID,
+		"createdAt": bson.M{"$gt": time.Now().Add(-magicLinkRateWindow)},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// silently dropped, a different answer would reveal that the account exists
+	if sent >= magicLinkRateMaxSent {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	now := time.Now()
+	magicLink := models.MagicLink{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(magicLinkTTL),
+	}
+	if _, err := magicLinkCollection.InsertOne(ctx, magicLink); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the signature makes the link unforgeable, the stored record makes it single-use
+	token, err := helper.SignToken(jwt.MapClaims{
+		"sub": user.ID.Hex(),
+		"jti": magicLink.ID.Hex(),
+		"typ": "magic_link",
+		"exp": magicLink.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// MAGIC_LINK_URL is a page of the web app that posts the token to /login/magic/consume, so
+	// that mail scanners following the link cannot use it up
+	link := os.Getenv("MAGIC_LINK_URL") + "?token=" + url.QueryEscape(token)
+	body := helper.RenderEmail("Your login link", user.Name, []string{
+		"Use the button below to log in to SocialHive. The link works once and expires in 15 minutes.",
+		"If you did not ask for it you can ignore this email.",
+	}, "Log in", link)
+	if err := helper.QueueEmail(user.Email, "Your SocialHive login link", body); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ConsumeMagicLink(c *gin.Context) {
+	type MagicLinkToken struct {
+		Token string `json:"token"`
+	}
+	var request MagicLinkToken
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := helper.VerifyToken(request.Token)
+	if err != nil || claims["typ"] != "magic_link" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login link"})
+		return
+	}
+	subject, _ := claims["sub"].(string)
+	linkIDHex, _ := claims["jti"].(string)
+	userID, err := primitive.ObjectIDFromHex(subject)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login link"})
+		return
+	}
+	linkID, err := primitive.ObjectIDFromHex(linkIDHex)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login link"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":       linkID,
+		"userId":    userID,
+		"usedAt":    time.Time{},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	var magicLink models.MagicLink
+	err = magicLinkCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"usedAt": time.Now()}}).Decode(&magicLink)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login link"})
+		return
+	}
+
+	user, err := helper.GetUserById(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login link"})
+		return
+	}
+
+	// a link sent before an email change must not log in through the old inbox
+	if user.Email != magicLink.Email {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login link"})
+		return
+	}
+
+	// the email inbox only replaces the password, a second factor is still asked for
+	completePrimaryLogin(c, user)
+}
@@ -8,6 +8,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
 	"socialhive/database"
+	"socialhive/dto"
 	"socialhive/helper"
 	"socialhive/models"
 	"time"
@@ -16,11 +17,24 @@ import (
 var msgCollection *mongo.Collection = database.OpenCollection(database.Client, "message-collection")
 
 func GetMessagesByUsers(c *gin.Context) {
-	user1Email := c.Param("user1")
-	user2Email := c.Param("user2")
+	// users are addressed by email or by handle
+	user1Address := c.Param("user1")
+	user2Address := c.Param("user2")
+
+	// Check if both users exist
+	user1, err := helper.GetUserByAddress(user1Address)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "One or both users not found"})
+		return
+	}
+	user2, err := helper.GetUserByAddress(user2Address)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "One or both users not found"})
+		return
+	}
 
 	// check whether client is requesting its own data
-	if !checkUserEmails(c, user1Email, user2Email) {
+	if !isParticipant(c, user1.ID, user2.ID) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "you are not allowed to request this data"})
 		return
 	}
@@ -28,18 +42,11 @@ func GetMessagesByUsers(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Check if both users exist
-	filter := bson.M{"email": bson.M{"$in": []string{user1Email, user2Email}}}
-	count, err := userCollection.CountDocuments(ctx, filter)
-	if err != nil || count < 2 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "One or both users not found"})
-		return
-	}
-
-	filter = bson.M{
+	// messages are matched by id as the embedded emails may be out of date
+	filter := bson.M{
 		"$or": []bson.M{
-			{"sender.email": user1Email, "recipient.email": user2Email},
-			{"sender.email": user2Email, "recipient.email": user1Email},
+			{"sender._id": user1.ID, "recipient._id": user2.ID},
+			{"sender._id": user2.ID, "recipient._id": user1.ID},
 		},
 	}
 
@@ -57,17 +64,13 @@ func GetMessagesByUsers(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, messages)
+	c.JSON(http.StatusOK, dto.NewMessages(messages))
 }
 
-func checkUserEmails(c *gin.Context, user1Email string, user2Email string) bool {
-	loggedInUserEmail := helper.ExtractEmail(c)
+func isParticipant(c *gin.Context, user1ID primitive.ObjectID, user2ID primitive.ObjectID) bool {
+	loggedInUserID := helper.GetPrincipal(c).ID
 
-	if loggedInUserEmail == user1Email || loggedInUserEmail == user2Email {
-		return true
-	} else {
-		return false
-	}
+	return loggedInUserID == user1ID || loggedInUserID == user2ID
 }
 
 func DeleteMessage(c *gin.Context) {
@@ -101,15 +104,12 @@ func DeleteMessage(c *gin.Context) {
 	}
 
 	// check the message is sent by the logged-in user
-	loggedInUserEmail := helper.ExtractEmail(c)
-	senderEmail := message.Sender.Email
-
-	if loggedInUserEmail != senderEmail {
+	if helper.GetPrincipal(c).ID != message.Sender.ID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to delete this message"})
 		return
 	}
 
 	msgCollection.FindOneAndDelete(ctx, bson.M{"_id": objectId})
-	c.JSON(http.StatusOK, message)
+	c.JSON(http.StatusOK, dto.NewMessage(message))
 
 }
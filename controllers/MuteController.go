@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"socialhive/dto"
+	"socialhive/helper"
+	"socialhive/models"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	mutedWordsMax        = 200
+	mutedPhraseMaxLength = 100
+	mutedPhraseMinLength = 2
+	muteMaxDuration      = 365 * 24 * time.Hour
+)
+
+func MuteUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// without a duration the mute lasts until the user is unmuted
+	type MuteRequest struct {
+		Duration string `json:"duration"`
+	}
+	var request MuteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	mute := models.MutedUser{UserID: userID, CreatedAt: time.Now()}
+	if request.Duration != "" {
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil || duration <= 0 || duration > muteMaxDuration {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duration must be a positive duration such as 24h, at most a year"})
+			return
+		}
+		mute.Until = mute.CreatedAt.Add(duration)
+	}
+
+	principal := helper.GetPrincipal(c)
+	if userID == principal.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot mute yourself"})
+		return
+	}
+	if _, err := helper.GetUserById(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// only the caller's document changes, the muted user is never told. Muting again replaces the
+	// earlier mute, which is how a mute is extended or made permanent
+	filter := bson.M{"_id": principal.ID}
+	if _, err := userCollection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"mutedUsers": bson.M{"userId": userID}}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := userCollection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"mutedUsers": mute}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": mute})
+}
+
+func UnmuteUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$pull": bson.M{"mutedUsers": bson.M{"userId": userID}}}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": helper.GetPrincipal(c).ID}, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this user is not muted"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unmuted successfully"})
+}
+
+func GetMutes(c *gin.Context) {
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	type MutedUserView struct {
+		User  dto.PublicUser `json:"user"`
+		Until time.Time      `json:"until"`
+	}
+	mutedUsers := []MutedUserView{}
+
+	mutedIDs := helper.MutedUserIDs(user)
+	if len(mutedIDs) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := userCollection.Find(ctx, bson.M{"_id": bson.M{"$in": mutedIDs}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		users := []models.User{}
+		if err := cursor.All(ctx, &users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		usersByID := map[primitive.ObjectID]models.User{}
+		for _, mutedUser := range users {
+			usersByID[mutedUser.ID] = mutedUser
+		}
+		for _, mute := range user.MutedUsers {
+			if mutedUser, ok := usersByID[mute.UserID]; ok && helper.MuteActive(mute) {
+				mutedUsers = append(mutedUsers, MutedUserView{User: dto.NewPublicUser(mutedUser), Until: mute.Until})
+			}
+		}
+	}
+
+	mutedWords := user.MutedWords
+	if mutedWords == nil {
+		mutedWords = []models.MutedWord{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"users": mutedUsers, "words": mutedWords}})
+}
+
+func AddMutedWord(c *gin.Context) {
+	type MutedWordRequest struct {
+		Phrase string `json:"phrase"`
+	}
+	var request MutedWordRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	phrase := strings.Join(strings.Fields(request.Phrase), " ")
+	if length := utf8.RuneCountInString(phrase); length < mutedPhraseMinLength || length > mutedPhraseMaxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "phrase must be " + strconv.Itoa(mutedPhraseMinLength) + " to " + strconv.Itoa(mutedPhraseMaxLength) + " characters long"})
+		return
+	}
+
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(user.MutedWords) >= mutedWordsMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you can mute at most " + strconv.Itoa(mutedWordsMax) + " words"})
+		return
+	}
+	for _, mutedWord := range user.MutedWords {
+		if helper.FoldSearchText(mutedWord.Phrase) == helper.FoldSearchText(phrase) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "this phrase is already muted"})
+			return
+		}
+	}
+
+	mutedWord := models.MutedWord{
+		ID:        primitive.NewObjectID(),
+		Phrase:    phrase,
+		CreatedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$push": bson.M{"mutedWords": mutedWord}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": mutedWord})
+}
+
+func DeleteMutedWord(c *gin.Context) {
+	wordID, err := primitive.ObjectIDFromHex(c.Param("word_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$pull": bson.M{"mutedWords": bson.M{"_id": wordID}}}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": helper.GetPrincipal(c).ID}, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no such muted word"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "word unmuted successfully"})
+}
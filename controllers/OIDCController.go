@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/oauth2"
+	"net/http"
+	"os"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"strings"
+	"time"
+)
+
+const (
+	oidcLoginTTL        = 10 * time.Minute
+	oidcStateCookie     = "oidc_state"
+	oidcStateCookiePath = "/auth/oidc/"
+)
+
+var oidcLoginCollection *mongo.Collection = database.OpenCollection(database.Client, "oidc-login-collection")
+
+func getOIDCProvider(c *gin.Context) (*helper.OIDCProvider, bool) {
+	provider, err := helper.GetOIDCProvider(c.Param("provider"))
+	if errors.Is(err, helper.ErrUnknownOIDCProvider) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "login provider is unavailable"})
+		return nil, false
+	}
+	return provider, true
+}
+
+func GetOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": helper.OIDCProviderNames()})
+}
+
+// BeginOIDCLogin redirects to the provider. The state ties the callback to this request, the
+// nonce ties the ID token to it and the PKCE verifier ties the code exchange to it.
+func BeginOIDCLogin(c *gin.Context) {
+	provider, ok := getOIDCProvider(c)
+	if !ok {
+		return
+	}
+
+	state, err := helper.GenerateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	nonce, err := helper.GenerateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	login := models.OIDCLogin{
+		ID:           primitive.NewObjectID(),
+		StateHash:    helper.HashToken(state),
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := oidcLoginCollection.InsertOne(ctx, login); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the state is kept in the browser as well, so that only the browser that started a login can
+	// finish it; otherwise anyone could send a victim their own callback and log them into the
+	// sender's account. Lax, because the callback arrives as a navigation from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcLoginTTL.Seconds()), oidcStateCookiePath, "", false, true)
+
+	authURL := provider.OAuth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(login.CodeVerifier))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func FinishOIDCLogin(c *gin.Context) {
+	provider, ok := getOIDCProvider(c)
+	if !ok {
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login was not completed: " + providerError})
+		return
+	}
+
+	if !oidcStateMatches(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired login, start again"})
+		return
+	}
+	c.SetCookie(oidcStateCookie, "", -1, oidcStateCookiePath, "", false, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// deleting the state makes every authorization response usable once
+	filter := bson.M{
+		"stateHash": helper.HashToken(c.Query("state")),
+		"provider":  provider.Name,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	var login models.OIDCLogin
+	if err := oidcLoginCollection.FindOneAndDelete(ctx, filter).Decode(&login); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired login, start again"})
+		return
+	}
+
+	identity, err := provider.Identify(ctx, c.Query("code"), login.CodeVerifier, login.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login could not be verified"})
+		return
+	}
+
+	user, err := userForIdentity(provider.Name, identity.Subject, identity.Email, identity.EmailVerified, identity.Name)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the provider replaces the password, a second factor is still asked for
+	completePrimaryLogin(c, user)
+}
+
+// oidcStateMatches reports whether the state of a callback is the one the browser was given when
+// it started the login.
+func oidcStateMatches(c *gin.Context) bool {
+	cookie, err := c.Cookie(oidcStateCookie)
+	state := c.Query("state")
+	return err == nil && state != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) == 1
+}
+
+// userForIdentity finds the user linked to a provider account. An unlinked account is linked to
+// the user with the same email, or becomes a new user, but only when the provider vouches for the
+// email; otherwise anyone could claim an address at a provider that does not check it.
+func userForIdentity(provider string, subject string, email string, emailVerified bool, name string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+	err := userCollection.FindOne(ctx, filter).Decode(&user)
+	if err == nil {
+		return withHandle(ctx, user)
+	}
+	if err != mongo.ErrNoDocuments {
+		return models.User{}, err
+	}
+
+	email = strings.TrimSpace(email)
+	if email == "" || !emailVerified {
+		return models.User{}, errors.New("the login provider did not confirm an email address")
+	}
+
+	identity := models.ExternalIdentity{
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+		LinkedAt: time.Now(),
+	}
+
+	user, err = helper.GetUserByEmail(email)
+	if err == nil {
+		_, err = userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$push": bson.M{"identities": identity}})
+		if err != nil {
+			return models.User{}, err
+		}
+		user.Identities = append(user.Identities, identity)
+		return withHandle(ctx, user)
+	}
+
+	// an address that was moved away from is still reserved by its previous owner
+	emailInUse, err := helper.EmailInUse(email)
+	if err != nil {
+		return models.User{}, err
+	}
+	if emailInUse {
+		return models.User{}, errors.New("this email is already in use")
+	}
+
+	if name == "" {
+		name = strings.Split(email, "@")[0]
+	}
+	handle, err := helper.GenerateHandle(name, email)
+	if err != nil {
+		return models.User{}, err
+	}
+	user = models.User{
+		ID:               primitive.NewObjectID(),
+		Name:             name,
+		Email:            email,
+		Handle:           handle,
+		HandleLower:      helper.NormalizeHandle(handle),
+		SearchTerms:      helper.SearchTerms(name, handle),
+		CreatedAt:        time.Now(),
+		Dp:               os.Getenv("DEFAULT_DP"),
+		Followers:        []primitive.ObjectID{},
+		Following:        []primitive.ObjectID{},
+		RequestsReceived: []models.FollowRequest{},
+		RequestsSent:     []models.FollowRequest{},
+		Identities:       []models.ExternalIdentity{identity},
+	}
+	if _, err := userCollection.InsertOne(ctx, user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// withHandle gives a user without a handle, such as one who signed up through a login provider
+// before handles were generated, one of their own.
+func withHandle(ctx context.Context, user models.User) (models.User, error) {
+	if user.Handle != "" {
+		return user, nil
+	}
+	handle, err := helper.GenerateHandle(user.Name, user.Email)
+	if err != nil {
+		return models.User{}, err
+	}
+	update := bson.M{"$set": bson.M{
+		"handle":      handle,
+		"handleLower": helper.NormalizeHandle(handle),
+		"searchTerms": helper.SearchTerms(user.Name, handle),
+	}}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID, "handle": bson.M{"$in": []interface{}{nil, ""}}}, update); err != nil {
+		return models.User{}, err
+	}
+	user.Handle = handle
+	user.HandleLower = helper.NormalizeHandle(handle)
+	return user, nil
+}
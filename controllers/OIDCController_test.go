@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func callbackContext(query string, cookie *http.Cookie) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?"+query, nil)
+	if cookie != nil {
+		c.Request.AddCookie(cookie)
+	}
+	return c
+}
+
+func TestOIDCStateMatchesOnlyTheStartingBrowser(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		cookie *http.Cookie
+		want   bool
+	}{
+		{name: "same browser", query: "state=state-1&code=x", cookie: &http.Cookie{Name: oidcStateCookie, Value: "state-1"}, want: true},
+		{name: "no cookie", query: "state=state-1&code=x"},
+		{name: "cookie of another login", query: "state=state-1&code=x", cookie: &http.Cookie{Name: oidcStateCookie, Value: "state-2"}},
+		{name: "no state", query: "code=x", cookie: &http.Cookie{Name: oidcStateCookie, Value: ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := oidcStateMatches(callbackContext(test.query, test.cookie)); got != test.want {
+				t.Errorf("oidcStateMatches = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
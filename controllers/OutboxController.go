@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"socialhive/database"
+	"socialhive/models"
+	"time"
+)
+
+var outboxCollection *mongo.Collection = database.OpenCollection(database.Client, "outbox")
+
+func GetOutboxMessages(c *gin.Context) {
+	// dead letters are what an admin usually wants to look at
+	status := c.DefaultQuery("status", models.OutboxStatusDead)
+
+	page, limit, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// bodies can contain one-time codes, so they are never listed
+	opts := options.Find().
+		SetSort(bson.M{"createdAt": -1}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit).
+		SetProjection(bson.M{"body": 0})
+
+	cursor, err := outboxCollection.Find(ctx, bson.M{"status": status}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.OutboxMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages, "page": page})
+}
+
+func RetryOutboxMessage(c *gin.Context) {
+	messageID, err := primitive.ObjectIDFromHex(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// sent messages are never resent; dead or still pending ones get a fresh set of attempts
+	filter := bson.M{
+		"_id":    messageID,
+		"status": bson.M{"$in": []string{models.OutboxStatusDead, models.OutboxStatusPending}},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":        models.OutboxStatusPending,
+			"attempts":      0,
+			"nextAttemptAt": time.Now(),
+		},
+		"$unset": bson.M{"expiresAt": ""},
+	}
+
+	result, err := outboxCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no dead or pending message with this id"})
+		return
+	}
+
+	recordAudit(c, "outbox.retry", "outbox", messageID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "message queued for retry"})
+}
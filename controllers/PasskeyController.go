@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"strings"
+	"time"
+)
+
+const (
+	webAuthnCeremonyTTL           = 5 * time.Minute
+	webAuthnPurposeRegistration   = "registration"
+	webAuthnPurposeAuthentication = "authentication"
+)
+
+var passkeyCollection *mongo.Collection = database.OpenCollection(database.Client, "passkey-collection")
+var webAuthnCeremonyCollection *mongo.Collection = database.OpenCollection(database.Client, "webauthn-ceremony-collection")
+
+// requireWebAuthn answers 503 when passkeys are not configured on this server.
+func requireWebAuthn(c *gin.Context) bool {
+	if helper.WebAuthn == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "passkeys are not enabled"})
+		return false
+	}
+	return true
+}
+
+// startCeremony stores the challenge of a ceremony and returns the id the client must send back.
+func startCeremony(ceremony models.WebAuthnCeremony) (primitive.ObjectID, error) {
+	ceremony.ID = primitive.NewObjectID()
+	ceremony.ExpiresAt = time.Now().Add(webAuthnCeremonyTTL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := webAuthnCeremonyCollection.InsertOne(ctx, ceremony)
+	return ceremony.ID, err
+}
+
+// takeCeremony loads and deletes a ceremony so that its challenge can only be answered once.
+func takeCeremony(c *gin.Context, userID primitive.ObjectID, purpose string) (models.WebAuthnCeremony, bool) {
+	ceremonyID, err := primitive.ObjectIDFromHex(c.Query("ceremony"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ceremony id"})
+		return models.WebAuthnCeremony{}, false
+	}
+
+	filter := bson.M{
+		"_id":       ceremonyID,
+		"userId":    userID,
+		"purpose":   purpose,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var ceremony models.WebAuthnCeremony
+	if err := webAuthnCeremonyCollection.FindOneAndDelete(ctx, filter).Decode(&ceremony); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired ceremony, start again"})
+		return models.WebAuthnCeremony{}, false
+	}
+	return ceremony, true
+}
+
+// errPasskeyReauthentication is returned when a registration is finished by another session than
+// the one that began it, or once its re-authentication no longer counts.
+var errPasskeyReauthentication = errors.New("enter your current password or log in again")
+
+// errPasskeyChallengeExpired is returned when a login is answered after its challenge timed out.
+var errPasskeyChallengeExpired = errors.New("passkey challenge expired")
+
+func BeginPasskeyRegistration(c *gin.Context) {
+	if !requireWebAuthn(c) {
+		return
+	}
+
+	// a passkey logs in without password or second factor, so a stolen session must not add one
+	type PasskeyRegistrationRequest struct {
+		CurrentPassword string `json:"currentPassword"`
+	}
+	var request PasskeyRegistrationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	principal := helper.GetPrincipal(c)
+	user, err := helper.GetWebAuthnUser(principal.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !reauthenticate(c, user.User, request.CurrentPassword) {
+		return
+	}
+
+	// discoverable credentials let the user log in without typing their email
+	var exclusions []protocol.CredentialDescriptor
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	creation, session, err := helper.WebAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ceremonyID, err := startCeremony(models.WebAuthnCeremony{
+		UserID:            user.User.ID,
+		Purpose:           webAuthnPurposeRegistration,
+		Session:           *session,
+		SessionID:         principal.SessionID,
+		ReauthenticatedAt: time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ceremonyId": ceremonyID, "options": creation})
+}
+
+func FinishPasskeyRegistration(c *gin.Context) {
+	if !requireWebAuthn(c) {
+		return
+	}
+
+	principal := helper.GetPrincipal(c)
+	user, err := helper.GetWebAuthnUser(principal.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ceremony, ok := takeCeremony(c, user.User.ID, webAuthnPurposeRegistration)
+	if !ok {
+		return
+	}
+
+	// the request body is the PublicKeyCredential returned by navigator.credentials.create()
+	credential, err := verifyPasskeyRegistration(user, ceremony, principal, c.Request)
+	if errors.Is(err, errPasskeyReauthentication) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+
+	passkey := models.Passkey{
+		ID:           primitive.NewObjectID(),
+		UserID:       user.User.ID,
+		Name:         name,
+		CredentialID: credential.ID,
+		Credential:   *credential,
+		CreatedAt:    time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := passkeyCollection.InsertOne(ctx, passkey); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": passkey})
+}
+
+// verifyPasskeyRegistration checks the authenticator's answer to a registration ceremony. Only the
+// session that re-authenticated to begin it may finish it, and only while that still counts.
+func verifyPasskeyRegistration(user helper.WebAuthnUser, ceremony models.WebAuthnCeremony, principal helper.Principal, request *http.Request) (*webauthn.Credential, error) {
+	if ceremony.SessionID != principal.SessionID || !helper.WithinReauthWindow(ceremony.ReauthenticatedAt) {
+		return nil, errPasskeyReauthentication
+	}
+	return helper.WebAuthn.FinishRegistration(user, ceremony.Session, request)
+}
+
+func BeginPasskeyLogin(c *gin.Context) {
+	if !requireWebAuthn(c) {
+		return
+	}
+
+	assertion, session, err := helper.WebAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the user is not known until the authenticator answers
+	ceremonyID, err := startCeremony(models.WebAuthnCeremony{
+		UserID:  primitive.NilObjectID,
+		Purpose: webAuthnPurposeAuthentication,
+		Session: *session,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ceremonyId": ceremonyID, "options": assertion})
+}
+
+func FinishPasskeyLogin(c *gin.Context) {
+	if !requireWebAuthn(c) {
+		return
+	}
+
+	ceremony, ok := takeCeremony(c, primitive.NilObjectID, webAuthnPurposeAuthentication)
+	if !ok {
+		return
+	}
+
+	owner, credential, err := verifyPasskeyLogin(ceremony, c.Request, helper.DiscoverWebAuthnUser)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "passkey could not be verified"})
+		return
+	}
+
+	// a counter that went backwards means the private key exists in more than one place
+	if credential.Authenticator.CloneWarning {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "passkey could not be verified"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, passkey := range owner.Passkeys {
+		if !bytes.Equal(passkey.CredentialID, credential.ID) {
+			continue
+		}
+		update := bson.M{"$set": bson.M{
+			"credential.authenticator.signcount": credential.Authenticator.SignCount,
+			"lastUsedAt":                         time.Now(),
+		}}
+		if _, err := passkeyCollection.UpdateOne(ctx, bson.M{"_id": passkey.ID}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// a passkey already proves possession and, usually, user verification, so no TOTP step follows
+	respondWithSession(c, owner.User)
+}
+
+// verifyPasskeyLogin checks the authenticator's answer to a login ceremony and returns the owner
+// of the passkey, whom discover finds from the user handle in that answer.
+func verifyPasskeyLogin(ceremony models.WebAuthnCeremony, request *http.Request, discover webauthn.DiscoverableUserHandler) (helper.WebAuthnUser, *webauthn.Credential, error) {
+	// the library only checks this when the user is known up front, not for discoverable logins
+	if !ceremony.Session.Expires.IsZero() && ceremony.Session.Expires.Before(time.Now()) {
+		return helper.WebAuthnUser{}, nil, errPasskeyChallengeExpired
+	}
+
+	var owner helper.WebAuthnUser
+	credential, err := helper.WebAuthn.FinishDiscoverableLogin(func(rawID []byte, userHandle []byte) (webauthn.User, error) {
+		user, err := discover(rawID, userHandle)
+		if err == nil {
+			owner = user.(helper.WebAuthnUser)
+		}
+		return user, err
+	}, ceremony.Session, request)
+	return owner, credential, err
+}
+
+func GetPasskeys(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := passkeyCollection.Find(ctx, bson.M{"userId": helper.GetPrincipal(c).ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	passkeys := []models.Passkey{}
+	if err := cursor.All(ctx, &passkeys); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": passkeys})
+}
+
+func DeletePasskey(c *gin.Context) {
+	passkeyID, err := primitive.ObjectIDFromHex(c.Param("passkey_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := passkeyCollection.DeleteOne(ctx, bson.M{"_id": passkeyID, "userId": helper.GetPrincipal(c).ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "passkey not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "passkey removed"})
+}
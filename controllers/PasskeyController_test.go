@@ -0,0 +1,382 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/http/httptest"
+	"socialhive/helper"
+	"socialhive/models"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "socialhive.test"
+	testOrigin = "https://socialhive.test"
+)
+
+// softwareAuthenticator stands in for a platform authenticator: it makes an ES256 key pair and
+// answers registration challenges with "none" attestation, as most passkey providers do.
+type softwareAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &softwareAuthenticator{key: key, credentialID: credentialID}
+}
+
+// create answers navigator.credentials.create() for the given options and origin.
+func (a *softwareAuthenticator) create(t *testing.T, creation *protocol.CredentialCreation, origin string) *http.Request {
+	t.Helper()
+	clientData, err := json.Marshal(map[string]string{
+		"type":      "webauthn.create",
+		"challenge": creation.Response.Challenge.String(),
+		"origin":    origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// COSE_Key of an EC2 P-256 key for ES256
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,
+		3:  -7,
+		-1: 1,
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(creation.Response.RelyingParty.ID))
+	var authData bytes.Buffer
+	authData.Write(rpIDHash[:])
+	// user present, user verified, attested credential data included
+	authData.WriteByte(0x01 | 0x04 | 0x40)
+	binary.Write(&authData, binary.BigEndian, uint32(0))
+	authData.Write(make([]byte, 16))
+	binary.Write(&authData, binary.BigEndian, uint16(len(a.credentialID)))
+	authData.Write(a.credentialID)
+	authData.Write(publicKey)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData.Bytes(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData),
+			"attestationObject": encode(attestationObject),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewRequest(http.MethodPost, "/me/passkeys/register/finish", bytes.NewReader(body))
+}
+
+// assert answers navigator.credentials.get() for the given options and origin, returning
+// userHandle as the owner of the credential.
+func (a *softwareAuthenticator) assert(t *testing.T, assertion *protocol.CredentialAssertion, origin string, userHandle []byte) *http.Request {
+	t.Helper()
+	clientData, err := json.Marshal(map[string]string{
+		"type":      "webauthn.get",
+		"challenge": assertion.Response.Challenge.String(),
+		"origin":    origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(assertion.Response.RelyingPartyID))
+	var authData bytes.Buffer
+	authData.Write(rpIDHash[:])
+	// user present, user verified
+	authData.WriteByte(0x01 | 0x04)
+	binary.Write(&authData, binary.BigEndian, uint32(1))
+
+	clientDataHash := sha256.Sum256(clientData)
+	signed := sha256.Sum256(append(authData.Bytes(), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, signed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authData.Bytes()),
+			"signature":         encode(signature),
+			"userHandle":        encode(userHandle),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewRequest(http.MethodPost, "/login/passkey/finish", bytes.NewReader(body))
+}
+
+// useTestWebAuthn turns passkeys on for the duration of a test.
+func useTestWebAuthn(t *testing.T) {
+	t.Helper()
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "SocialHive",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := helper.WebAuthn
+	helper.WebAuthn = w
+	t.Cleanup(func() { helper.WebAuthn = previous })
+}
+
+// beginTestRegistration starts a registration the way BeginPasskeyRegistration does once the
+// caller has re-authenticated.
+func beginTestRegistration(t *testing.T, user helper.WebAuthnUser, sessionID primitive.ObjectID, reauthenticatedAt time.Time) (*protocol.CredentialCreation, models.WebAuthnCeremony) {
+	t.Helper()
+	creation, session, err := helper.WebAuthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creation, models.WebAuthnCeremony{
+		UserID:            user.User.ID,
+		Purpose:           webAuthnPurposeRegistration,
+		Session:           *session,
+		SessionID:         sessionID,
+		ReauthenticatedAt: reauthenticatedAt,
+	}
+}
+
+func TestVerifyPasskeyRegistration(t *testing.T) {
+	useTestWebAuthn(t)
+	user := helper.WebAuthnUser{User: models.User{ID: primitive.NewObjectID(), Name: "Jane Doe", Email: "jane@example.com"}}
+	principal := helper.Principal{ID: user.User.ID, SessionID: primitive.NewObjectID()}
+
+	t.Run("re-authenticated session", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t)
+		creation, ceremony := beginTestRegistration(t, user, principal.SessionID, time.Now())
+
+		credential, err := verifyPasskeyRegistration(user, ceremony, principal, authenticator.create(t, creation, testOrigin))
+		if err != nil {
+			t.Fatalf("registration failed: %v", err)
+		}
+		if !bytes.Equal(credential.ID, authenticator.credentialID) {
+			t.Errorf("registered credential %x, want %x", credential.ID, authenticator.credentialID)
+		}
+	})
+
+	t.Run("finished by another session", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t)
+		creation, ceremony := beginTestRegistration(t, user, primitive.NewObjectID(), time.Now())
+
+		_, err := verifyPasskeyRegistration(user, ceremony, principal, authenticator.create(t, creation, testOrigin))
+		if !errors.Is(err, errPasskeyReauthentication) {
+			t.Errorf("expected errPasskeyReauthentication, got %v", err)
+		}
+	})
+
+	t.Run("re-authentication no longer counts", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t)
+		creation, ceremony := beginTestRegistration(t, user, principal.SessionID, time.Now().Add(-time.Hour))
+
+		_, err := verifyPasskeyRegistration(user, ceremony, principal, authenticator.create(t, creation, testOrigin))
+		if !errors.Is(err, errPasskeyReauthentication) {
+			t.Errorf("expected errPasskeyReauthentication, got %v", err)
+		}
+	})
+
+	t.Run("ceremony never re-authenticated", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t)
+		creation, ceremony := beginTestRegistration(t, user, principal.SessionID, time.Time{})
+
+		_, err := verifyPasskeyRegistration(user, ceremony, principal, authenticator.create(t, creation, testOrigin))
+		if !errors.Is(err, errPasskeyReauthentication) {
+			t.Errorf("expected errPasskeyReauthentication, got %v", err)
+		}
+	})
+
+	t.Run("answer from another origin", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t)
+		creation, ceremony := beginTestRegistration(t, user, principal.SessionID, time.Now())
+
+		_, err := verifyPasskeyRegistration(user, ceremony, principal, authenticator.create(t, creation, "https://phishing.test"))
+		if err == nil || errors.Is(err, errPasskeyReauthentication) {
+			t.Errorf("expected the attestation to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("answer to another challenge", func(t *testing.T) {
+		authenticator := newSoftwareAuthenticator(t)
+		creation, _ := beginTestRegistration(t, user, principal.SessionID, time.Now())
+		_, ceremony := beginTestRegistration(t, user, principal.SessionID, time.Now())
+
+		_, err := verifyPasskeyRegistration(user, ceremony, principal, authenticator.create(t, creation, testOrigin))
+		if err == nil || errors.Is(err, errPasskeyReauthentication) {
+			t.Errorf("expected the attestation to be rejected, got %v", err)
+		}
+	})
+}
+
+// registerTestPasskey registers the authenticator's key for user and returns the user with it.
+func registerTestPasskey(t *testing.T, user helper.WebAuthnUser, authenticator *softwareAuthenticator) helper.WebAuthnUser {
+	t.Helper()
+	principal := helper.Principal{ID: user.User.ID, SessionID: primitive.NewObjectID()}
+	creation, ceremony := beginTestRegistration(t, user, principal.SessionID, time.Now())
+	credential, err := verifyPasskeyRegistration(user, ceremony, principal, authenticator.create(t, creation, testOrigin))
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	user.Passkeys = append(user.Passkeys, models.Passkey{
+		ID:           primitive.NewObjectID(),
+		UserID:       user.User.ID,
+		CredentialID: credential.ID,
+		Credential:   *credential,
+	})
+	return user
+}
+
+// beginTestLogin starts a login the way BeginPasskeyLogin does.
+func beginTestLogin(t *testing.T) (*protocol.CredentialAssertion, models.WebAuthnCeremony) {
+	t.Helper()
+	assertion, session, err := helper.WebAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assertion, models.WebAuthnCeremony{
+		UserID:  primitive.NilObjectID,
+		Purpose: webAuthnPurposeAuthentication,
+		Session: *session,
+	}
+}
+
+// discoverAmong looks users up the way helper.DiscoverWebAuthnUser does, with users in place of
+// the database.
+func discoverAmong(users ...helper.WebAuthnUser) webauthn.DiscoverableUserHandler {
+	return func(rawID []byte, userHandle []byte) (webauthn.User, error) {
+		for _, user := range users {
+			if bytes.Equal(user.WebAuthnID(), userHandle) {
+				return user, nil
+			}
+		}
+		return nil, errors.New("unknown user handle")
+	}
+}
+
+func TestVerifyPasskeyLogin(t *testing.T) {
+	useTestWebAuthn(t)
+	authenticator := newSoftwareAuthenticator(t)
+	user := registerTestPasskey(t, helper.WebAuthnUser{User: models.User{ID: primitive.NewObjectID(), Name: "Jane Doe", Email: "jane@example.com"}}, authenticator)
+	other := helper.WebAuthnUser{User: models.User{ID: primitive.NewObjectID(), Name: "John Roe", Email: "john@example.com"}}
+	discover := discoverAmong(user, other)
+
+	t.Run("accepted assertion", func(t *testing.T) {
+		assertion, ceremony := beginTestLogin(t)
+
+		owner, credential, err := verifyPasskeyLogin(ceremony, authenticator.assert(t, assertion, testOrigin, user.WebAuthnID()), discover)
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		if owner.User.ID != user.User.ID {
+			t.Errorf("logged in as %s, want %s", owner.User.ID.Hex(), user.User.ID.Hex())
+		}
+		if !bytes.Equal(credential.ID, authenticator.credentialID) {
+			t.Errorf("used credential %x, want %x", credential.ID, authenticator.credentialID)
+		}
+		if credential.Authenticator.CloneWarning {
+			t.Error("unexpected clone warning")
+		}
+	})
+
+	t.Run("answer to another challenge", func(t *testing.T) {
+		assertion, _ := beginTestLogin(t)
+		_, ceremony := beginTestLogin(t)
+
+		if _, _, err := verifyPasskeyLogin(ceremony, authenticator.assert(t, assertion, testOrigin, user.WebAuthnID()), discover); err == nil {
+			t.Error("expected the assertion to be rejected")
+		}
+	})
+
+	t.Run("expired challenge", func(t *testing.T) {
+		assertion, ceremony := beginTestLogin(t)
+		ceremony.Session.Expires = time.Now().Add(-time.Second)
+
+		_, _, err := verifyPasskeyLogin(ceremony, authenticator.assert(t, assertion, testOrigin, user.WebAuthnID()), discover)
+		if !errors.Is(err, errPasskeyChallengeExpired) {
+			t.Errorf("expected errPasskeyChallengeExpired, got %v", err)
+		}
+	})
+
+	t.Run("answer from another origin", func(t *testing.T) {
+		assertion, ceremony := beginTestLogin(t)
+
+		if _, _, err := verifyPasskeyLogin(ceremony, authenticator.assert(t, assertion, "https://phishing.test", user.WebAuthnID()), discover); err == nil {
+			t.Error("expected the assertion to be rejected")
+		}
+	})
+
+	t.Run("unknown user handle", func(t *testing.T) {
+		assertion, ceremony := beginTestLogin(t)
+		unknown := primitive.NewObjectID()
+
+		if _, _, err := verifyPasskeyLogin(ceremony, authenticator.assert(t, assertion, testOrigin, unknown[:]), discover); err == nil {
+			t.Error("expected the assertion to be rejected")
+		}
+	})
+
+	t.Run("user handle that is not a user id", func(t *testing.T) {
+		assertion, ceremony := beginTestLogin(t)
+
+		// rejected by helper.DiscoverWebAuthnUser before it looks anything up
+		if _, _, err := verifyPasskeyLogin(ceremony, authenticator.assert(t, assertion, testOrigin, []byte("not-a-user-id")), helper.DiscoverWebAuthnUser); err == nil {
+			t.Error("expected the assertion to be rejected")
+		}
+	})
+
+	t.Run("credential of another user", func(t *testing.T) {
+		assertion, ceremony := beginTestLogin(t)
+
+		if _, _, err := verifyPasskeyLogin(ceremony, authenticator.assert(t, assertion, testOrigin, other.WebAuthnID()), discover); err == nil {
+			t.Error("expected the assertion to be rejected")
+		}
+	})
+}
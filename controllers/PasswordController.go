@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"strconv"
+	"time"
+)
+
+const passwordResetTTL = 24 * time.Hour
+
+var passwordResetCollection *mongo.Collection = database.OpenCollection(database.Client, "password-reset-collection")
+
+// reauthenticate checks that the caller is the account owner and not someone with a stolen
+// session: they either enter their current password or logged in moments ago. Accounts made
+// through a login provider have no password and can only use the latter.
+func reauthenticate(c *gin.Context, user models.User, password string) bool {
+	if password != "" {
+		// a stolen session must not become a way around the limits on guessing at login
+		retryAfter, err := helper.LoginRetryAfter(user.Email, c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, try again later"})
+			return false
+		}
+
+		if ok, _ := helper.VerifyPassword(user.Password, password); !ok {
+			locked, err := helper.RecordLoginFailure(user.Email, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return false
+			}
+			if locked {
+				sendLockoutAlert(c, user)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid password"})
+			return false
+		}
+		// failures are not cleared here; only a complete login does that, see respondWithSession
+		return true
+	}
+
+	principal := helper.GetPrincipal(c)
+	if !helper.RecentlyAuthenticated(principal.SessionID, principal.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "enter your current password or log in again"})
+		return false
+	}
+	return true
+}
+
+func ChangePassword(c *gin.Context) {
+	type PasswordChange struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	var request PasswordChange
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal := helper.GetPrincipal(c)
+	user, err := helper.GetUserById(principal.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !reauthenticate(c, user, request.CurrentPassword) {
+		return
+	}
+
+	if err := helper.CheckPasswordPolicy(request.NewPassword, user.Name, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := helper.HashPassword(request.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"password": hashedPassword}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// everyone else who was logged in, possibly with the old password, has to log in again
+	if err := helper.RevokeSessions(user.ID, bson.M{"_id": bson.M{"$ne": principal.SessionID}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := helper.RenderEmail("Your password was changed", user.Name, []string{
+		"The password of your SocialHive account was changed from " + c.ClientIP() + " at " + time.Now().UTC().Format(time.RFC1123) + ". All other devices were logged out.",
+		"If this wasn't you, contact us right away so that we can secure your account.",
+	}, "", "")
+	if err := helper.QueueEmail(user.Email, "Your SocialHive password was changed", body); err != nil {
+		log.Println("failed to queue password change notice:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+// sendPasswordReset emails a user a link to choose a new password. Only the latest link works.
+func sendPasswordReset(user models.User) error {
+	token, err := helper.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := passwordResetCollection.DeleteMany(ctx, bson.M{"userId": user.ID}); err != nil {
+		return err
+	}
+	now := time.Now()
+	passwordReset := models.PasswordReset{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		TokenHash: helper.HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetTTL),
+	}
+	if _, err := passwordResetCollection.InsertOne(ctx, passwordReset); err != nil {
+		return err
+	}
+
+	// PASSWORD_RESET_URL is a page of the web app that asks for the new password and posts it
+	// with the token to /password/reset
+	link := os.Getenv("PASSWORD_RESET_URL") + "?token=" + url.QueryEscape(token)
+	body := helper.RenderEmail("Choose a new password", user.Name, []string{
+		"To log in to SocialHive again you have to choose a new password. Use the button below, the link works once and expires in 24 hours.",
+		"Every device that was logged in to your account has been logged out.",
+	}, "Choose a new password", link)
+	return helper.QueueEmail(user.Email, "Choose a new SocialHive password", body)
+}
+
+func ResetPassword(c *gin.Context) {
+	type PasswordResetRequest struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+	var request PasswordResetRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"tokenHash": helper.HashToken(request.Token),
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	var passwordReset models.PasswordReset
+	if err := passwordResetCollection.FindOne(ctx, filter).Decode(&passwordReset); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired link"})
+		return
+	}
+
+	user, err := helper.GetUserById(passwordReset.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired link"})
+		return
+	}
+
+	// checked before the link is used up, so that a rejected password can be corrected
+	if err := helper.CheckPasswordPolicy(request.NewPassword, user.Name, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hashedPassword, err := helper.HashPassword(request.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// deleting makes the link single-use, also when it is posted twice at once
+	result, err := passwordResetCollection.DeleteOne(ctx, bson.M{"_id": passwordReset.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired link"})
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"password": hashedPassword, "passwordResetDue": false}}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := helper.RevokeSessions(user.ID, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := helper.ClearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset, log in with your new password"})
+}
@@ -11,8 +11,9 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"slices"
 	"socialhive/database"
+	"socialhive/dto"
 	"socialhive/helper"
 	"socialhive/models"
 	"time"
@@ -21,13 +22,8 @@ import (
 var postCollection *mongo.Collection = database.OpenCollection(database.Client, "posts-collection")
 
 func CreatePost(c *gin.Context) {
-	// uploader id
-	hexId := c.PostForm("uploader")
-	uploader, err := primitive.ObjectIDFromHex(hexId)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+	// posts are always made as the logged in user
+	uploader := helper.GetPrincipal(c).ID
 
 	text := c.PostForm("text")
 	form, err := c.MultipartForm()
@@ -51,6 +47,12 @@ func CreatePost(c *gin.Context) {
 		imageIds = append(imageIds, fileId)
 	}
 
+	mentions, err := helper.ExtractMentions(text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	post := models.Post{
 		ID:        primitive.NewObjectID(),
 		Text:      text,
@@ -59,6 +61,7 @@ func CreatePost(c *gin.Context) {
 		Images:    imageIds,
 		LikedBy:   []primitive.ObjectID{},
 		Comments:  []models.Comment{},
+		Mentions:  mentions,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -80,7 +83,7 @@ func CreatePost(c *gin.Context) {
 	//	return
 	//}
 
-	c.JSON(http.StatusCreated, gin.H{"post": post})
+	c.JSON(http.StatusCreated, gin.H{"post": dto.NewPost(post)})
 }
 
 func uploadToGridFS(bucket *gridfs.Bucket, file *multipart.FileHeader) (string, error) {
@@ -112,10 +115,31 @@ func GetPostsByUserId(c *gin.Context) {
 		return
 	}
 
+	user, err := helper.GetUserById(userId)
+	if err != nil || hiddenFromCaller(c, user) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !contentVisibleToCaller(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this account is private"})
+		return
+	}
+
 	//userCollection := database.OpenCollection(database.Client, "user-collection")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	viewer, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	blocked, err := helper.BlockedUserIDs(viewer.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	var posts []models.Post
 
 	cursor, err := postCollection.Find(ctx, bson.M{"uploader": userId})
@@ -130,13 +154,51 @@ func GetPostsByUserId(c *gin.Context) {
 		posts = append(posts, post)
 	}
 
-	for i := range posts {
-		for j := range posts[i].Images {
-			posts[i].Images[j] = os.Getenv("HOST_NAME") + "images/" + posts[i].Images[j] // Construct the URL
+	// a profile someone opens shows all of its posts, only the comments they muted are left out
+	posts = withoutMutedContent(withoutComments(posts, blocked), viewer, false)
+	c.JSON(http.StatusOK, gin.H{"posts": dto.NewPosts(posts)})
+}
+
+// withoutMutedContent drops what the viewer muted: comments by muted accounts or containing a
+// muted word and, when dropPosts is set, the posts containing a muted word. What the viewer wrote
+// themselves is always kept.
+func withoutMutedContent(posts []models.Post, viewer models.User, dropPosts bool) []models.Post {
+	mutedIDs := helper.MutedUserIDs(viewer)
+	muted := helper.MutedWordsMatcher(viewer.MutedWords)
+
+	filtered := []models.Post{}
+	for _, post := range posts {
+		if dropPosts && post.Uploader != viewer.ID && muted(post.Text) {
+			continue
+		}
+		comments := []models.Comment{}
+		for _, comment := range post.Comments {
+			if comment.CommenterID != viewer.ID && (slices.Contains(mutedIDs, comment.CommenterID) || muted(comment.CommentText)) {
+				continue
+			}
+			comments = append(comments, comment)
 		}
+		post.Comments = comments
+		filtered = append(filtered, post)
 	}
+	return filtered
+}
 
-	c.JSON(http.StatusOK, gin.H{"posts": posts})
+// withoutComments drops the comments the given users wrote from a list of posts.
+func withoutComments(posts []models.Post, commenterIDs []primitive.ObjectID) []models.Post {
+	if len(commenterIDs) == 0 {
+		return posts
+	}
+	for i := range posts {
+		comments := []models.Comment{}
+		for _, comment := range posts[i].Comments {
+			if !slices.Contains(commenterIDs, comment.CommenterID) {
+				comments = append(comments, comment)
+			}
+		}
+		posts[i].Comments = comments
+	}
+	return posts
 }
 
 func GetImage(c *gin.Context) {
@@ -149,6 +211,36 @@ func GetImage(c *gin.Context) {
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// an image is shown to whoever may see what it belongs to: a post to those who may see the
+	// uploader's posts, an avatar to those the profile is not hidden from. Images belonging to
+	// neither are not served
+	var post models.Post
+	err = postCollection.FindOne(ctx, bson.M{"images": imageId}).Decode(&post)
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err == nil {
+		uploader, err := helper.GetUserById(post.Uploader)
+		if err != nil || hiddenFromCaller(c, uploader) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		if !contentVisibleToCaller(c, uploader) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this account is private"})
+			return
+		}
+	} else {
+		var owner models.User
+		if err := userCollection.FindOne(ctx, bson.M{"avatarId": imageId}).Decode(&owner); err != nil || hiddenFromCaller(c, owner) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+	}
+
 	// Retrieve the image from GridFS
 	file, err := database.GridFSBucket.OpenDownloadStream(fileID)
 	if err != nil {
@@ -170,7 +262,6 @@ func GetImage(c *gin.Context) {
 
 func UpdateLikes(c *gin.Context) {
 	postID := c.Param("post_id") // Get post ID from URL params
-	userID := c.Param("user_id") // Get user ID from URL params
 	action := c.Param("action")  // Get action (increment or decrement) from query params
 
 	// Convert IDs to ObjectID
@@ -180,11 +271,7 @@ func UpdateLikes(c *gin.Context) {
 		return
 	}
 
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
-	}
+	userObjectID := helper.GetPrincipal(c).ID
 
 	postCollection := database.OpenCollection(database.Client, "posts-collection")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -202,8 +289,9 @@ func UpdateLikes(c *gin.Context) {
 		return
 	}
 
-	// Log the update query for debugging
-	fmt.Println("Update Query:", update)
+	if _, ok := visiblePost(ctx, c, postObjectID); !ok {
+		return
+	}
 
 	result, err := postCollection.UpdateOne(ctx, bson.M{"_id": postObjectID}, update)
 	if err != nil {
@@ -213,9 +301,6 @@ func UpdateLikes(c *gin.Context) {
 		return
 	}
 
-	// Log the result of the update for debugging
-	fmt.Println("Update Result:", result)
-
 	if result.ModifiedCount == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found or no changes made"})
 		return
@@ -224,29 +309,60 @@ func UpdateLikes(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Like status updated successfully"})
 }
 
+// visiblePost loads a post the caller wants to like or comment on. The posts of someone on the
+// other side of a block or of a restricted account are not found, those of a private account are
+// only open to its followers, the same as when the posts are listed. When the post cannot be
+// used the response has been written already.
+func visiblePost(ctx context.Context, c *gin.Context, postID primitive.ObjectID) (models.Post, bool) {
+	var post models.Post
+	if err := postCollection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return post, false
+	}
+	uploader, err := helper.GetUserById(post.Uploader)
+	if err != nil || hiddenFromCaller(c, uploader) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return post, false
+	}
+	if !contentVisibleToCaller(c, uploader) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this account is private"})
+		return post, false
+	}
+	return post, true
+}
+
 func AddComment(c *gin.Context) {
 	postIDHex := c.PostForm("post_id")
-	userIDHex := c.PostForm("user_id")
 	text := c.PostForm("text")
+	userID := helper.GetPrincipal(c).ID
 
-	userID, err := primitive.ObjectIDFromHex(userIDHex)
+	postID, err := primitive.ObjectIDFromHex(postIDHex)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID format"})
 		return
 	}
 
-	postID, err := primitive.ObjectIDFromHex(postIDHex)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := visiblePost(ctx, c, postID); !ok {
+		return
+	}
+
+	mentions, err := helper.ExtractMentions(text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	comment := models.Comment{
 		ID:          primitive.NewObjectID(),
 		CommenterID: userID,
 		CommentText: text,
 		CommentedAt: time.Now(),
+		Mentions:    mentions,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
 	update := bson.M{"$push": bson.M{"comments": comment}}
 
 	_, err = postCollection.UpdateOne(ctx, bson.M{"_id": postID}, update)
@@ -255,7 +371,7 @@ func AddComment(c *gin.Context) {
 		fmt.Println(err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": comment})
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewComment(comment)})
 }
 
 func GetUserFeedsByID(c *gin.Context) {
@@ -267,20 +383,55 @@ func GetUserFeedsByID(c *gin.Context) {
 	}
 
 	user, err := helper.GetUserById(userId)
-	if err != nil {
+	if err != nil || hiddenFromCaller(c, user) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	// a feed is made of the accounts a user follows, which private accounts only show their followers
+	if !contentVisibleToCaller(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this account is private"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// posts of suspended or banned accounts stay out of the feed, and so do those of private
+	// accounts when someone other than their followers asks for this feed
+	callerID := helper.GetPrincipal(c).ID
+	viewer := user
+	if user.ID != callerID {
+		if viewer, err = helper.GetUserById(callerID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	blocked, err := helper.BlockedUserIDs(callerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// muted accounts are left out just like blocked ones, without them ever being told
+	hidden := append(blocked, helper.MutedUserIDs(viewer)...)
+	readable := bson.M{"$or": []bson.M{{"isPublic": true}, {"followers": callerID}, {"_id": callerID}}}
+	visible := bson.M{"$and": []bson.M{
+		{"_id": bson.M{"$in": user.Following, "$nin": hidden}},
+		helper.UnrestrictedAccountFilter(),
+		readable,
+	}}
+	uploaders, err := userCollection.Distinct(ctx, "_id", visible)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	filter := bson.M{
 		"uploader": bson.M{
-			"$in": user.Following,
+			"$in": uploaders,
 		},
 	}
 
 	var posts []models.Post
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
 	cursor, err := postCollection.Find(ctx, filter)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -293,11 +444,6 @@ func GetUserFeedsByID(c *gin.Context) {
 		posts = append(posts, post)
 	}
 
-	for i := range posts {
-		for j := range posts[i].Images {
-			posts[i].Images[j] = os.Getenv("HOST_NAME") + "images/" + posts[i].Images[j] // Construct the URL
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{"posts": posts})
+	posts = withoutMutedContent(withoutComments(posts, blocked), viewer, true)
+	c.JSON(http.StatusOK, gin.H{"posts": dto.NewPosts(posts)})
 }
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"socialhive/database"
+	"socialhive/dto"
+	"socialhive/helper"
+	"socialhive/models"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	profileNameMaxLength     = 50
+	profileBioMaxLength      = 300
+	profileWebsiteMaxLength  = 200
+	profilePronounsMaxLength = 30
+	avatarMaxUploadSize      = 5 << 20
+)
+
+// profileField trims an optional field and checks its length, naming the field in the error.
+func profileField(name string, value string, maxLength int) (string, error) {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) > maxLength {
+		return "", errors.New(name + " must be at most " + strconv.Itoa(maxLength) + " characters long")
+	}
+	return value, nil
+}
+
+func UpdateProfile(c *gin.Context) {
+	// pointers tell fields left out, which stay as they are, from fields being cleared
+	type ProfileUpdate struct {
+		Name     *string `json:"name"`
+		Bio      *string `json:"bio"`
+		Website  *string `json:"website"`
+		Pronouns *string `json:"pronouns"`
+	}
+	var request ProfileUpdate
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	set := bson.M{}
+	if request.Name != nil {
+		name, err := profileField("name", *request.Name, profileNameMaxLength)
+		if err == nil && name == "" {
+			err = errors.New("name must not be empty")
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		set["name"] = name
+	}
+	if request.Bio != nil {
+		bio, err := profileField("bio", *request.Bio, profileBioMaxLength)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		set["bio"] = bio
+	}
+	if request.Website != nil {
+		website, err := profileField("website", *request.Website, profileWebsiteMaxLength)
+		if err == nil && website != "" {
+			// only web links, a javascript: link on a profile page would run in the viewer's browser
+			parsed, parseErr := url.Parse(website)
+			if parseErr != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				err = errors.New("website must be an http or https link")
+			}
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		set["website"] = website
+	}
+	if request.Pronouns != nil {
+		pronouns, err := profileField("pronouns", *request.Pronouns, profilePronounsMaxLength)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		set["pronouns"] = pronouns
+	}
+	if len(set) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to update"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := userCollection.FindOneAndUpdate(ctx, bson.M{"_id": helper.GetPrincipal(c).ID}, bson.M{"$set": set}, opts).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Name != nil {
+		// the handle is needed as well, so the search terms follow once the name is stored
+		update := bson.M{"$set": bson.M{"searchTerms": helper.SearchTerms(user.Name, user.Handle)}}
+		if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewSelfUser(user)})
+}
+
+func SetAccountPrivacy(c *gin.Context) {
+	type PrivacySetting struct {
+		IsPublic *bool `json:"isPublic"`
+	}
+	var request PrivacySetting
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.IsPublic == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "isPublic is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the setting is stored first, so that requests sent from here on are accepted as they come in
+	// and only those already pending are left to accept below
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	update := bson.M{"$set": bson.M{"isPublic": *request.IsPublic}}
+	err := userCollection.FindOneAndUpdate(ctx, bson.M{"_id": helper.GetPrincipal(c).ID}, update, opts).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.IsPublic && len(user.RequestsReceived) > 0 {
+		// the same checks as AcceptFollowRequest: requests of suspended or banned senders, and of
+		// those on either side of a block, stay pending
+		blocked, err := helper.BlockedUserIDs(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		pendingIDs := []primitive.ObjectID{}
+		for _, followRequest := range user.RequestsReceived {
+			pendingIDs = append(pendingIDs, followRequest.From)
+		}
+		filter := bson.M{"$and": []bson.M{
+			{"_id": bson.M{"$in": pendingIDs, "$nin": blocked}},
+			helper.UnrestrictedAccountFilter(),
+		}}
+		acceptable, err := userCollection.Distinct(ctx, "_id", filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		senderIDs := []primitive.ObjectID{}
+		requestIDs := []primitive.ObjectID{}
+		for _, followRequest := range user.RequestsReceived {
+			if slices.Contains(acceptable, interface{}(followRequest.From)) {
+				senderIDs = append(senderIDs, followRequest.From)
+				requestIDs = append(requestIDs, followRequest.ID)
+			}
+		}
+
+		update = bson.M{
+			"$addToSet": bson.M{"followers": bson.M{"$each": senderIDs}},
+			"$pull":     bson.M{"requestsReceived": bson.M{"_id": bson.M{"$in": requestIDs}}},
+		}
+		if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		update = bson.M{
+			"$addToSet": bson.M{"following": user.ID},
+			"$pull":     bson.M{"requestsSent": bson.M{"_id": bson.M{"$in": requestIDs}}},
+		}
+		if _, err := userCollection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": senderIDs}}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if user, err = helper.GetUserById(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewSelfUser(user)})
+}
+
+func UploadAvatar(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fileHeader.Size > avatarMaxUploadSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the file is too large"})
+		return
+	}
+
+	// the crop square is optional, without it the largest centred square is kept
+	var crop helper.CropSquare
+	for field, target := range map[string]*int{"x": &crop.X, "y": &crop.Y, "size": &crop.Size} {
+		if value := c.PostForm(field); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + field})
+				return
+			}
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	data, err := helper.ReadLimited(file, avatarMaxUploadSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	avatar, err := helper.ProcessAvatar(data, crop)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	avatarID := primitive.NewObjectID()
+	if err := database.GridFSBucket.UploadFromStreamWithID(avatarID, "avatar.png", bytes.NewReader(avatar)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := replaceAvatar(c, avatarID.Hex())
+	if !ok {
+		database.GridFSBucket.Delete(avatarID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewSelfUser(user)})
+}
+
+func DeleteAvatar(c *gin.Context) {
+	user, ok := replaceAvatar(c, "")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewSelfUser(user)})
+}
+
+// replaceAvatar points the caller's profile at a new picture, or back at the default one when
+// avatarID is empty, and deletes the picture it replaces.
+func replaceAvatar(c *gin.Context, avatarID string) (models.User, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var previous models.User
+	filter := bson.M{"_id": helper.GetPrincipal(c).ID}
+	err := userCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"avatarId": avatarID}}).Decode(&previous)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+
+	if previousID, err := primitive.ObjectIDFromHex(previous.AvatarID); err == nil {
+		if err := database.GridFSBucket.Delete(previousID); err != nil {
+			log.Println("failed to delete previous avatar:", err)
+		}
+	}
+
+	user := previous
+	user.AvatarID = avatarID
+	return user, true
+}
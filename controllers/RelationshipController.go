@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"slices"
+	"socialhive/dto"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+const relationshipMutualSample = 3
+
+func GetRelationship(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	viewer, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if userID == viewer.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this is your own account"})
+		return
+	}
+
+	// someone who blocked the viewer stays out of sight, while a user the viewer blocked is shown
+	// so that they can be unblocked
+	user, err := helper.GetUserById(userID)
+	if err != nil || slices.Contains(user.Blocked, viewer.ID) || (helper.AccountRestricted(user) && hiddenFromCaller(c, user)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	type Relationship struct {
+		Following  bool `json:"following"`
+		FollowedBy bool `json:"followedBy"`
+		// the pending follow request in each direction, if any
+		RequestSentID     *primitive.ObjectID `json:"requestSentId"`
+		RequestReceivedID *primitive.ObjectID `json:"requestReceivedId"`
+		Blocking          bool                `json:"blocking"`
+		Muting            bool                `json:"muting"`
+		MutedUntil        *time.Time          `json:"mutedUntil"`
+		// people the viewer follows who follow the user, a few of them and how many there are
+		Mutuals     []dto.PublicUser `json:"mutuals"`
+		MutualCount int64            `json:"mutualCount"`
+	}
+
+	relationship := Relationship{
+		Following:  slices.Contains(viewer.Following, user.ID),
+		FollowedBy: slices.Contains(user.Following, viewer.ID),
+		Blocking:   slices.Contains(viewer.Blocked, user.ID),
+		Mutuals:    []dto.PublicUser{},
+	}
+	for _, followRequest := range viewer.RequestsSent {
+		if followRequest.To == user.ID {
+			relationship.RequestSentID = &followRequest.ID
+		}
+	}
+	for _, followRequest := range viewer.RequestsReceived {
+		if followRequest.From == user.ID {
+			relationship.RequestReceivedID = &followRequest.ID
+		}
+	}
+	for _, mute := range viewer.MutedUsers {
+		if mute.UserID == user.ID && helper.MuteActive(mute) {
+			relationship.Muting = true
+			if !mute.Until.IsZero() {
+				relationship.MutedUntil = &mute.Until
+			}
+		}
+	}
+
+	// the followers of a private account are only shown to those who may see its content, and
+	// mutuals are some of those followers
+	mutualIDs := []primitive.ObjectID{}
+	if contentVisibleToCaller(c, user) {
+		for _, followerID := range user.Followers {
+			if slices.Contains(viewer.Following, followerID) {
+				mutualIDs = append(mutualIDs, followerID)
+			}
+		}
+	}
+	if len(mutualIDs) > 0 {
+		blocked, err := helper.BlockedUserIDs(viewer.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{"$and": []bson.M{
+			{"_id": bson.M{"$in": mutualIDs, "$nin": blocked}},
+			helper.UnrestrictedAccountFilter(),
+		}}
+		if relationship.MutualCount, err = userCollection.CountDocuments(ctx, filter); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cursor, err := userCollection.Find(ctx, filter, options.Find().SetLimit(relationshipMutualSample))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		mutuals := []models.User{}
+		if err := cursor.All(ctx, &mutuals); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, mutual := range mutuals {
+			relationship.Mutuals = append(relationship.Mutuals, dto.NewPublicUser(mutual))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": relationship})
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"regexp"
+	"slices"
+	"socialhive/dto"
+	"socialhive/helper"
+	"socialhive/models"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	searchQueryMaxLength = 100
+	searchMaxWords       = 5
+	// how many users a single search ranks at most, per kind of match
+	searchCandidateLimit = 500
+)
+
+// searchTypos is how many typos a query word may contain and still match; short words have to
+// be typed exactly, or nearly everything would match them.
+func searchTypos(word string) int {
+	switch length := utf8.RuneCountInString(word); {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	}
+	return 2
+}
+
+// searchPrefix is the start of a query word that fuzzy candidates must share. Typos in the first
+// two letters are not forgiven, that keeps the candidate query on the searchTerms index.
+func searchPrefix(word string, fuzzy bool) string {
+	if fuzzy && searchTypos(word) > 0 {
+		return string([]rune(word)[:2])
+	}
+	return word
+}
+
+// searchCandidates finds the searchable users whose terms start with each query word, leaving out
+// the excluded ones.
+func searchCandidates(ctx context.Context, words []string, fuzzy bool, excluded []primitive.ObjectID) ([]models.User, error) {
+	and := []bson.M{helper.UnrestrictedAccountFilter(), {"_id": bson.M{"$nin": excluded}}}
+	for _, word := range words {
+		prefix := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(searchPrefix(word, fuzzy))}
+		and = append(and, bson.M{"searchTerms": prefix})
+	}
+
+	cursor, err := userCollection.Find(ctx, bson.M{"$and": and}, options.Find().SetLimit(searchCandidateLimit))
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// searchWordScore rates how well a query word matches the best of a user's terms: exactly,
+// as the start of a term, or with a few typos. Zero means it does not match.
+func searchWordScore(word string, terms []string) int {
+	best := 0
+	for _, term := range terms {
+		score := 0
+		switch {
+		case term == word:
+			score = 6
+		case strings.HasPrefix(term, word):
+			score = 5
+		default:
+			// a typo in what has been typed so far counts, as well as one in the whole term
+			distance := helper.EditDistance(word, term)
+			if runes := []rune(term); len(runes) > utf8.RuneCountInString(word) {
+				distance = min(distance, helper.EditDistance(word, string(runes[:utf8.RuneCountInString(word)])))
+			}
+			if distance <= searchTypos(word) {
+				score = 4 - distance
+			}
+		}
+		best = max(best, score)
+	}
+	return best
+}
+
+type searchResult struct {
+	user  models.User
+	score int
+}
+
+func SearchUsers(c *gin.Context) {
+	page, limit, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	if utf8.RuneCountInString(query) > searchQueryMaxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the search is too long"})
+		return
+	}
+	words := helper.SearchTokens(query)
+	if len(words) == 0 {
+		c.JSON(http.StatusOK, gin.H{"data": []interface{}{}, "total": 0, "page": page})
+		return
+	}
+	if len(words) > searchMaxWords {
+		words = words[:searchMaxWords]
+	}
+
+	viewerID := helper.GetPrincipal(c).ID
+	blocked, err := helper.BlockedUserIDs(viewerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// exact prefixes are looked up on their own, so that the fuzzy candidates, which are many more,
+	// cannot crowd them out of the candidate limit
+	candidates, err := searchCandidates(ctx, words, false, blocked)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	fuzzyCandidates, err := searchCandidates(ctx, words, true, blocked)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	handle := helper.NormalizeHandle(query)
+	seen := map[primitive.ObjectID]bool{}
+	results := []searchResult{}
+	for _, user := range append(candidates, fuzzyCandidates...) {
+		if seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+
+		score := 0
+		for _, word := range words {
+			wordScore := searchWordScore(word, user.SearchTerms)
+			if wordScore == 0 {
+				score = 0
+				break
+			}
+			score += wordScore
+		}
+		if score == 0 {
+			continue
+		}
+
+		// typing someone's whole handle means looking for exactly them
+		if user.HandleLower != "" && user.HandleLower == handle {
+			score += 20
+		}
+		// people the caller knows come before strangers with the same name
+		if slices.Contains(user.Followers, viewerID) {
+			score += 4
+		}
+		if slices.Contains(user.Following, viewerID) {
+			score += 3
+		}
+		results = append(results, searchResult{user: user, score: score})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		if len(results[i].user.Followers) != len(results[j].user.Followers) {
+			return len(results[i].user.Followers) > len(results[j].user.Followers)
+		}
+		return results[i].user.Name < results[j].user.Name
+	})
+
+	users := []models.User{}
+	start := min((page-1)*limit, int64(len(results)))
+	end := min(start+limit, int64(len(results)))
+	for _, result := range results[start:end] {
+		users = append(users, result.user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewUsers(users, viewerID), "total": len(results), "page": page})
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"slices"
+	"socialhive/database"
+	"socialhive/dto"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+var suggestionCollection *mongo.Collection = database.OpenCollection(database.Client, "suggestion-collection")
+
+func GetSuggestions(c *gin.Context) {
+	page, limit, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the worker keeps the suggestions of active users fresh, anyone else gets theirs computed now
+	var set models.SuggestionSet
+	err = suggestionCollection.FindOne(ctx, bson.M{"userId": user.ID}).Decode(&set)
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err == mongo.ErrNoDocuments || time.Since(set.ComputedAt) > helper.GetEnvDuration("SUGGESTION_MAX_AGE", 24*time.Hour) {
+		if set, err = helper.RefreshSuggestions(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// follows, requests, blocks and dismissals made since the suggestions were computed still count
+	excluded, err := helper.SuggestionExclusions(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	suggestions := []models.Suggestion{}
+	for _, suggestion := range set.Suggestions {
+		if !slices.Contains(excluded, suggestion.UserID) {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	total := len(suggestions)
+	start := min((page-1)*limit, int64(total))
+	end := min(start+limit, int64(total))
+	suggestions = suggestions[start:end]
+
+	ids := []primitive.ObjectID{}
+	for _, suggestion := range suggestions {
+		ids = append(ids, suggestion.UserID)
+	}
+	usersByID := map[primitive.ObjectID]models.User{}
+	if len(ids) > 0 {
+		filter := bson.M{"$and": []bson.M{{"_id": bson.M{"$in": ids}}, helper.UnrestrictedAccountFilter()}}
+		cursor, err := userCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		users := []models.User{}
+		if err := cursor.All(ctx, &users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, suggestedUser := range users {
+			usersByID[suggestedUser.ID] = suggestedUser
+		}
+	}
+
+	type SuggestionView struct {
+		User        dto.PublicUser       `json:"user"`
+		MutualCount int                  `json:"mutualCount"`
+		MutualIDs   []primitive.ObjectID `json:"mutualIds"`
+	}
+	views := []SuggestionView{}
+	for _, suggestion := range suggestions {
+		if suggestedUser, ok := usersByID[suggestion.UserID]; ok {
+			views = append(views, SuggestionView{
+				User:        dto.NewPublicUser(suggestedUser),
+				MutualCount: suggestion.MutualCount,
+				MutualIDs:   suggestion.MutualIDs,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": views, "total": total, "page": page})
+}
+
+func DismissSuggestion(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	principal := helper.GetPrincipal(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$addToSet": bson.M{"hiddenSuggestions": userID}}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": principal.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	update = bson.M{"$pull": bson.M{"suggestions": bson.M{"userId": userID}}}
+	if _, err := suggestionCollection.UpdateOne(ctx, bson.M{"userId": principal.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "suggestion dismissed successfully"})
+}
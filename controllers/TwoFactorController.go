@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"net/http"
+	"socialhive/helper"
+	"socialhive/models"
+	"strconv"
+	"time"
+)
+
+const (
+	twoFactorPendingTTL = 5 * time.Minute
+	recoveryCodeCount   = 10
+)
+
+// completePrimaryLogin continues a login once the first factor checked out. Users with two-factor
+// authentication get a short-lived pending token to exchange at /login/2fa instead of a session.
+func completePrimaryLogin(c *gin.Context, user models.User) {
+	// told only after the first factor, so that the status of an account cannot be probed
+	if helper.AccountRestricted(user) {
+		c.JSON(http.StatusForbidden, helper.AccountRestriction(user))
+		return
+	}
+
+	if !user.TotpEnabled {
+		respondWithSession(c, user)
+		return
+	}
+
+	pendingToken, err := helper.SignToken(jwt.MapClaims{
+		"sub": user.ID.Hex(),
+		"typ": "2fa_pending",
+		"exp": time.Now().Add(twoFactorPendingTTL).Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"twoFactorRequired": true, "pendingToken": pendingToken})
+}
+
+func VerifyTwoFactorLogin(c *gin.Context) {
+	type TwoFactorLogin struct {
+		PendingToken string `json:"pendingToken"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	var request TwoFactorLogin
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := helper.VerifyToken(request.PendingToken)
+	if err != nil || claims["typ"] != "2fa_pending" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login, start again"})
+		return
+	}
+	subject, _ := claims["sub"].(string)
+	userID, err := primitive.ObjectIDFromHex(subject)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login, start again"})
+		return
+	}
+
+	user, err := helper.GetUserById(userID)
+	if err != nil || !user.TotpEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login, start again"})
+		return
+	}
+
+	// wrong codes count towards the same lockout as wrong passwords
+	retryAfter, err := helper.LoginRetryAfter(user.Email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if retryAfter > 0 {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, try again later"})
+		return
+	}
+
+	ok, err := verifySecondFactor(user, request.Code, request.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		if locked, _ := helper.RecordLoginFailure(user.Email, c.ClientIP()); locked {
+			sendLockoutAlert(c, user)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return
+	}
+
+	respondWithSession(c, user)
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, a recovery code and marks it as used so
+// that it cannot be replayed.
+func verifySecondFactor(user models.User, code string, recoveryCode string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if code != "" {
+		step, ok := helper.ValidateTotp(user.TotpSecret, code, user.TotpLastStep)
+		if !ok {
+			return false, nil
+		}
+		// conditional on the last step so that two concurrent logins cannot use the same code
+		filter := bson.M{"_id": user.ID, "totpLastStep": bson.M{"$lt": step}}
+		result, err := userCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totpLastStep": step}})
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	if recoveryCode != "" {
+		hash := helper.HashToken(helper.NormalizeRecoveryCode(recoveryCode))
+		filter := bson.M{"_id": user.ID, "recoveryCodes": hash}
+		result, err := userCollection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recoveryCodes": hash}})
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	return false, nil
+}
+
+func EnrollTotp(c *gin.Context) {
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := helper.GenerateTotpSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the secret only becomes active once the user proves their app generates matching codes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"totpPendingSecret": secret}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"secret": secret,
+		"uri":    helper.TotpURI(secret, user.Email),
+	}})
+}
+
+func ConfirmTotp(c *gin.Context) {
+	type Code struct {
+		Code string `json:"code"`
+	}
+	var code Code
+	if err := c.ShouldBind(&code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.TotpEnabled || user.TotpPendingSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no two-factor enrollment in progress"})
+		return
+	}
+
+	step, ok := helper.ValidateTotp(user.TotpPendingSecret, code.Code, 0)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return
+	}
+
+	recoveryCodes, hashes, err := helper.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	update := bson.M{
+		"$set": bson.M{
+			"totpEnabled":   true,
+			"totpSecret":    user.TotpPendingSecret,
+			"totpLastStep":  step,
+			"recoveryCodes": hashes,
+		},
+		"$unset": bson.M{"totpPendingSecret": ""},
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// recovery codes are only ever shown here
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"recoveryCodes": recoveryCodes}})
+}
+
+// reauthenticateWithSecondFactor loads the caller and checks both their password and a second
+// factor, writing the error response when either is wrong.
+func reauthenticateWithSecondFactor(c *gin.Context) (models.User, bool) {
+	type SecondFactorReauth struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	var request SecondFactorReauth
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+
+	user, err := helper.GetUserById(helper.GetPrincipal(c).ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+
+	if !user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is not enabled"})
+		return models.User{}, false
+	}
+
+	if !reauthenticate(c, user, request.Password) {
+		return models.User{}, false
+	}
+
+	// wrong codes count towards the login lockout here as well, or a stolen session could guess them
+	retryAfter, err := helper.LoginRetryAfter(user.Email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, try again later"})
+		return models.User{}, false
+	}
+
+	ok, err := verifySecondFactor(user, request.Code, request.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+	if !ok {
+		if locked, _ := helper.RecordLoginFailure(user.Email, c.ClientIP()); locked {
+			sendLockoutAlert(c, user)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+func DisableTotp(c *gin.Context) {
+	user, ok := reauthenticateWithSecondFactor(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	update := bson.M{
+		"$set": bson.M{"totpEnabled": false},
+		"$unset": bson.M{
+			"totpSecret":        "",
+			"totpPendingSecret": "",
+			"totpLastStep":      "",
+			"recoveryCodes":     "",
+		},
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := reauthenticateWithSecondFactor(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, hashes, err := helper.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// replaces the old codes, used or not
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"recoveryCodes": hashes}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"recoveryCodes": recoveryCodes}})
+}
@@ -2,44 +2,61 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	_ "github.com/go-playground/validator/v10"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
+	"log"
+	"math"
 	"net/http"
 	"os"
+	"slices"
 	"socialhive/database"
+	"socialhive/dto"
 	"socialhive/helper"
 	"socialhive/models"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var validate = validator.New()
+
+// dummyPasswordHash is compared against when logging in to an unknown account
+var dummyPasswordHash, _ = helper.HashPassword("socialhive-unknown-user")
 var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user-collection")
 
 func SignUp(c *gin.Context) {
-	// fetch json data and store to user
-	var user models.User
-	if err := c.ShouldBind(&user); err != nil {
+	// only what a new user may choose is bound, so that nobody signs up with roles or a status
+	type SignUpRequest struct {
+		Name     string `json:"name" validate:"required"`
+		Email    string `json:"email" validate:"required"`
+		Password string `json:"password" validate:"required"`
+		Handle   string `json:"handle" validate:"required"`
+	}
+	var request SignUpRequest
+	if err := c.ShouldBind(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	fmt.Println(user)
+	user := models.User{
+		Name:     request.Name,
+		Email:    request.Email,
+		Password: request.Password,
+		Handle:   strings.TrimPrefix(strings.TrimSpace(request.Handle), "@"),
+	}
 
 	// check whether same email exists
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	filter := bson.M{"email": user.Email}
-	var foundUser models.User
-	err := userCollection.FindOne(ctx, filter).Decode(&foundUser)
-	if err == nil {
+	emailInUse, err := helper.EmailInUse(user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if emailInUse {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "This email is already in use"})
 		return
 	}
@@ -51,14 +68,35 @@ func SignUp(c *gin.Context) {
 		return
 	}
 
+	if err := helper.CheckPasswordPolicy(user.Password, user.Name, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := helper.ValidateHandle(user.Handle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	handleInUse, err := helper.HandleInUse(user.Handle, primitive.NilObjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if handleInUse {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this handle is already taken"})
+		return
+	}
+	user.HandleLower = helper.NormalizeHandle(user.Handle)
+	user.SearchTerms = helper.SearchTerms(user.Name, user.Handle)
+
 	// hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := helper.HashPassword(user.Password)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 
 	// add timestamp
 	user.CreatedAt, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -129,6 +167,10 @@ func CreateUserByOtp(c *gin.Context) {
 
 	// store the user in db
 	insertedUser, err := userCollection.InsertOne(ctx, tempUser.User)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this handle was taken in the meantime, sign up again with another one"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -152,48 +194,137 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// slow down guessing; the limits apply whether or not the account exists
+	retryAfter, err := helper.LoginRetryAfter(user.Email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, try again later"})
+		return
+	}
+
 	// check whether user exists
 	var foundUser models.User
 	filter := bson.M{"email": user.Email}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	err := userCollection.FindOne(ctx, filter).Decode(&foundUser)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user does not exists"})
+	err = userCollection.FindOne(ctx, filter).Decode(&foundUser)
+	userExists := err == nil
+
+	// match the passwords, against a dummy hash for unknown users so both cases take as long
+	passwordHash := dummyPasswordHash
+	if userExists {
+		passwordHash = foundUser.Password
+	}
+	passwordMatches, needsRehash := helper.VerifyPassword(passwordHash, user.Password)
+
+	// the same answer for unknown users and wrong passwords, so accounts cannot be enumerated
+	if !userExists || !passwordMatches {
+		locked, err := helper.RecordLoginFailure(user.Email, c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if locked && userExists {
+			sendLockoutAlert(c, foundUser)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email or password"})
 		return
 	}
 
-	// match the passwords
-	err = bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(user.Password))
+	// the plain password is only at hand now, so this is the moment to move to the current hasher
+	if needsRehash {
+		upgradePasswordHash(foundUser, user.Password)
+	}
+
+	completePrimaryLogin(c, foundUser)
+}
+
+// upgradePasswordHash replaces a stored hash made with outdated settings. Failing to do so only
+// postpones the upgrade to the next login.
+func upgradePasswordHash(user models.User, password string) {
+	hashedPassword, err := helper.HashPassword(password)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid password"})
+		log.Println("failed to rehash password:", err)
 		return
 	}
 
-	// create jwt token
-	// Create a new token object, specifying signing method and the claims
-	// you would like it to contain.
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.Email,
-		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// conditional on the old hash so that a password changed meanwhile is not overwritten
+	filter := bson.M{"_id": user.ID, "password": user.Password}
+	if _, err := userCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"password": hashedPassword}}); err != nil {
+		log.Println("failed to store rehashed password:", err)
+	}
+}
+
+// sendLockoutAlert tells the owner of an account that it was locked after repeated failed logins.
+func sendLockoutAlert(c *gin.Context, user models.User) {
+	body := helper.RenderEmail("Your account was locked", user.Name, []string{
+		"We noticed several failed attempts to log in to your SocialHive account, the last one from " + c.ClientIP() + " at " + time.Now().UTC().Format(time.RFC1123) + ".",
+		"Logins are blocked for a while. If this wasn't you, consider changing your password once you can log in again.",
+	}, "", "")
+	if err := helper.QueueEmail(user.Email, "Failed login attempts on your SocialHive account", body); err != nil {
+		log.Println("failed to queue lockout alert:", err)
+	}
+}
 
-	// Sign and get the complete encoded token as a string using the secret
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+// respondWithSession logs the user in. Browsers get the session token in a cookie, while clients
+// that ask for ?mode=token (mobile app, scripts) get it in the body to send as a bearer token.
+func respondWithSession(c *gin.Context, user models.User) {
+	if helper.AccountRestricted(user) {
+		c.JSON(http.StatusForbidden, helper.AccountRestriction(user))
+		return
+	}
 
+	// no way of logging in works until a password reset is done, and whoever gets this far is
+	// sent a fresh link in case the earlier one expired. It goes to the account's own address
+	if user.PasswordResetDue {
+		if err := sendPasswordReset(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "a password reset is required, use the link sent to your email"})
+		return
+	}
+
+	// create a session and its jwt token
+	tokenString, session, err := helper.CreateSession(c, user)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// failures are only forgotten once the whole login succeeded; a right password alone must not
+	// reset the count while the second factor is being guessed
+	if err := helper.ClearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("mode") == "token" {
+		c.JSON(http.StatusOK, gin.H{"data": dto.NewSelfUser(user), "token": tokenString, "expiresAt": session.ExpiresAt})
+		return
+	}
+
 	// send jwt-token in cookie
 	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("token", tokenString, 3600*24*30, "/", "", false, true)
+	c.SetCookie("token", tokenString, int(helper.SessionTTL.Seconds()), "/", "", false, true)
 
-	c.JSON(http.StatusOK, gin.H{"data": foundUser})
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewSelfUser(user)})
 }
 
 func Logout(c *gin.Context) {
+	// end the session so that copies of the token stop working too
+	if tokenString := helper.TokenFromRequest(c); tokenString != "" {
+		if userID, sessionID, err := helper.ParseSessionToken(tokenString); err == nil {
+			helper.RevokeSessions(userID, bson.M{"_id": sessionID})
+		}
+	}
+
 	// Clear the JWT token by setting the cookie with an expired time
 	c.SetCookie("token", "", -1, "/", "", false, true)
 
@@ -202,22 +333,26 @@ func Logout(c *gin.Context) {
 }
 
 func ValidateUser(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user does not exist"})
-		return
-	}
-	userDetails := user.(models.User)
+	principal := helper.GetPrincipal(c)
 
-	c.JSON(http.StatusOK, gin.H{"user got validated": userDetails.Email})
+	c.JSON(http.StatusOK, gin.H{"user got validated": principal.Email})
 
 }
 
 func GetAllUsers(c *gin.Context) {
+	viewerID := helper.GetPrincipal(c).ID
+	blocked, err := helper.BlockedUserIDs(viewerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	var users []models.User
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cursor, err := userCollection.Find(ctx, bson.M{})
+	// the directory leaves out both sides of a block and the accounts that are suspended or banned
+	filter := bson.M{"$and": []bson.M{helper.UnrestrictedAccountFilter(), {"_id": bson.M{"$nin": blocked}}}}
+	cursor, err := userCollection.Find(ctx, filter)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -226,7 +361,34 @@ func GetAllUsers(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": users})
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewUsers(users, viewerID)})
+}
+
+// hiddenFromCaller reports whether the profile and posts of a user are kept from the caller,
+// because either of them blocked the other or because the user is suspended or banned. Staff
+// still see restricted accounts in order to review them.
+func hiddenFromCaller(c *gin.Context, user models.User) bool {
+	principal := helper.GetPrincipal(c)
+	if user.ID == principal.ID {
+		return false
+	}
+	if blocked, err := helper.IsBlocked(principal.ID, user.ID); err != nil || blocked {
+		return true
+	}
+	if !helper.AccountRestricted(user) {
+		return false
+	}
+	return !principal.HasRole(helper.RoleModerator) && !principal.HasRole(helper.RoleAdmin)
+}
+
+// contentVisibleToCaller reports whether the caller may see the posts, followers and following of
+// a user. Public accounts show them to everyone, private accounts only to approved followers.
+func contentVisibleToCaller(c *gin.Context, user models.User) bool {
+	principal := helper.GetPrincipal(c)
+	if user.IsPublic || user.ID == principal.ID || slices.Contains(user.Followers, principal.ID) {
+		return true
+	}
+	return principal.HasRole(helper.RoleModerator) || principal.HasRole(helper.RoleAdmin)
 }
 
 func GetUserById(c *gin.Context) {
@@ -246,55 +408,9 @@ func GetUserById(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	loggedInUserEmail := helper.ExtractEmail(c)
-
-	if user.Email != loggedInUserEmail {
-		type UserToSend struct {
-			ID        primitive.ObjectID   `json:"_id"`
-			Name      string               `json:"name"`
-			Email     string               `json:"email"`
-			CreatedAt time.Time            `json:"createdAt"`
-			Dp        string               `json:"dp"`
-			Followers []primitive.ObjectID `json:"followers"`
-			Following []primitive.ObjectID `json:"following"`
-		}
-
-		userToSend := UserToSend{
-			ID:        user.ID,
-			Name:      user.Name,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-			Dp:        user.Dp,
-			Followers: user.Followers,
-			Following: user.Following,
-		}
-
-		c.JSON(http.StatusOK, gin.H{"data": userToSend})
-	} else {
-		type UserToSend struct {
-			ID               primitive.ObjectID     `json:"_id"`
-			Name             string                 `json:"name"`
-			Email            string                 `json:"email"`
-			CreatedAt        time.Time              `json:"createdAt"`
-			Dp               string                 `json:"dp"`
-			Followers        []primitive.ObjectID   `json:"followers"`
-			Following        []primitive.ObjectID   `json:"following"`
-			RequestsReceived []models.FollowRequest `json:"requestsReceived"`
-			RequestsSent     []models.FollowRequest `json:"requestsSent"`
-		}
-
-		userToSend := UserToSend{
-			ID:               user.ID,
-			Name:             user.Name,
-			Email:            user.Email,
-			CreatedAt:        user.CreatedAt,
-			Dp:               user.Dp,
-			Followers:        user.Followers,
-			Following:        user.Following,
-			RequestsSent:     user.RequestsSent,
-			RequestsReceived: user.RequestsReceived,
-		}
-
-		c.JSON(http.StatusOK, gin.H{"data": userToSend})
+	if hiddenFromCaller(c, user) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewUser(user, helper.GetPrincipal(c).ID)})
 }
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+const wsTicketTTL = 30 * time.Second
+
+func IssueWSTicket(c *gin.Context) {
+	principal := helper.GetPrincipal(c)
+
+	ticket, err := helper.GenerateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	wsTicket := models.WSTicket{
+		ID:         primitive.NewObjectID(),
+		TicketHash: helper.HashToken(ticket),
+		UserID:     principal.ID,
+		SessionID:  principal.SessionID,
+		ExpiresAt:  time.Now().Add(wsTicketTTL),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wsTicketCollection := database.OpenCollection(database.Client, "ws-ticket-collection")
+	if _, err := wsTicketCollection.InsertOne(ctx, wsTicket); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"ticket": ticket, "expiresAt": wsTicket.ExpiresAt}})
+}
@@ -17,6 +17,10 @@ func DBinstance() *mongo.Client {
 	intializers.LoadEnvVariables()
 
 	MongoUri := os.Getenv("MONGO_URI")
+	if MongoUri == "" {
+		// connecting is lazy, so packages still load without a database, as they do in tests
+		MongoUri = "mongodb://localhost:27017"
+	}
 	client, err := mongo.NewClient(options.Client().ApplyURI(MongoUri))
 	if err != nil {
 		panic(err)
@@ -47,3 +51,14 @@ func BucketInstance() *gridfs.Bucket {
 }
 
 var GridFSBucket *gridfs.Bucket = BucketInstance()
+
+func NamedBucketInstance(name string) *gridfs.Bucket {
+	gridFsBucket, err := gridfs.NewBucket(Client.Database(os.Getenv("DB_NAME")), options.GridFSBucket().SetName(name))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return gridFsBucket
+}
+
+// ExportBucket keeps data export archives apart from what users uploaded.
+var ExportBucket *gridfs.Bucket = NamedBucketInstance("exports")
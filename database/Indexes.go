@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+// EnsureIndexes creates the indexes the application relies on. Creating an index that already
+// exists is a no-op, so this is safe to run on every start.
+func EnsureIndexes() {
+	indexes := map[string][]mongo.IndexModel{
+		"outbox": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"user-collection": {
+			{Keys: bson.D{{Key: "email", Value: 1}}},
+			{Keys: bson.D{{Key: "previousEmails", Value: 1}}},
+			{Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}}},
+			{
+				Keys:    bson.D{{Key: "handleLower", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"handleLower": bson.M{"$type": "string"}}),
+			},
+			{Keys: bson.D{{Key: "previousHandles", Value: 1}}},
+			{Keys: bson.D{{Key: "searchTerms", Value: 1}}},
+			{Keys: bson.D{{Key: "blocked", Value: 1}}},
+			{Keys: bson.D{{Key: "mutedUsers.userId", Value: 1}}},
+			{Keys: bson.D{{Key: "avatarId", Value: 1}}},
+		},
+		"posts-collection": {
+			{Keys: bson.D{{Key: "images", Value: 1}}},
+		},
+		"session-collection": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "revokedAt", Value: 1}}},
+		},
+		"ws-ticket-collection": {
+			{Keys: bson.D{{Key: "ticketHash", Value: 1}}},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"passkey-collection": {
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+			{Keys: bson.D{{Key: "credentialId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"webauthn-ceremony-collection": {
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"magic-link-collection": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(24 * 60 * 60)},
+		},
+		"oidc-login-collection": {
+			{Keys: bson.D{{Key: "stateHash", Value: 1}}},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"audit-log-collection": {
+			{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+			{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "createdAt", Value: -1}}},
+			{Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		},
+		"account-deletion-collection": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "scheduledFor", Value: 1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "status", Value: 1}}},
+		},
+		"data-export-collection": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+		},
+		"suggestion-collection": {
+			{Keys: bson.D{{Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"email-change-collection": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "revertTokenHash", Value: 1}}},
+		},
+		"password-reset-collection": {
+			{Keys: bson.D{{Key: "tokenHash", Value: 1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for collectionName, models := range indexes {
+		collection := OpenCollection(Client, collectionName)
+		if _, err := collection.Indexes().CreateMany(ctx, models); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
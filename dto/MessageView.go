@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"socialhive/models"
+	"time"
+)
+
+// Participant is a sender or recipient of a message. The email stored with a message stays on
+// the server, people are addressed by their handle.
+type Participant struct {
+	ID     primitive.ObjectID `json:"_id"`
+	Handle string             `json:"handle"`
+}
+
+type Message struct {
+	ID        primitive.ObjectID `json:"_id"`
+	Sender    Participant        `json:"sender"`
+	Recipient Participant        `json:"recipient"`
+	Content   string             `json:"content"`
+	Timestamp time.Time          `json:"timestamp"`
+	Status    string             `json:"status"`
+}
+
+func NewParticipant(participant models.MessageParticipant) Participant {
+	return Participant{
+		ID:     participant.ID,
+		Handle: participant.Handle,
+	}
+}
+
+func NewMessage(message models.Message) Message {
+	return Message{
+		ID:        message.ID,
+		Sender:    NewParticipant(message.Sender),
+		Recipient: NewParticipant(message.Recipient),
+		Content:   message.Content,
+		Timestamp: message.Timestamp,
+		Status:    message.Status,
+	}
+}
+
+func NewMessages(messages []models.Message) []Message {
+	views := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		views = append(views, NewMessage(message))
+	}
+	return views
+}
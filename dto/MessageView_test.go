@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"socialhive/models"
+	"testing"
+	"time"
+)
+
+func TestNewMessagesHidesParticipantEmails(t *testing.T) {
+	messages := []models.Message{{
+		ID:        primitive.NewObjectID(),
+		Sender:    models.MessageParticipant{ID: primitive.NewObjectID(), Handle: "jane", Email: "jane@example.com"},
+		Recipient: models.MessageParticipant{ID: primitive.NewObjectID(), Handle: "john", Email: "john@example.com"},
+		Content:   "hello",
+		Timestamp: time.Now(),
+		Status:    "sent",
+	}}
+
+	body := marshalView(t, NewMessages(messages))
+
+	assertAbsent(t, body, `"email"`, "jane@example.com", "john@example.com")
+	assertAbsent(t, body, secrets...)
+	assertPresent(t, body, `"handle":"jane"`, `"handle":"john"`, `"content":"hello"`)
+}
+
+func TestNewMessagesEncodesEmptyListAsArray(t *testing.T) {
+	if body := marshalView(t, NewMessages(nil)); body != "[]" {
+		t.Errorf("expected [], got %s", body)
+	}
+}
@@ -0,0 +1,72 @@
+package dto
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"socialhive/models"
+	"time"
+)
+
+type Comment struct {
+	ID          primitive.ObjectID   `json:"_id"`
+	CommenterID primitive.ObjectID   `json:"commenter_id"`
+	CommentText string               `json:"comment_text"`
+	CommentedAt time.Time            `json:"commented_at"`
+	Mentions    []primitive.ObjectID `json:"mentions"`
+}
+
+type Post struct {
+	ID        primitive.ObjectID   `json:"_id"`
+	Uploader  primitive.ObjectID   `json:"uploader"`
+	Text      string               `json:"text"`
+	CreatedAt time.Time            `json:"createdAt"`
+	LikedBy   []primitive.ObjectID `json:"likedBy"`
+	Images    []string             `json:"images"`
+	Comments  []Comment            `json:"comments"`
+	Mentions  []primitive.ObjectID `json:"mentions"`
+}
+
+// ImageURL is where clients download an image stored in GridFS.
+func ImageURL(imageID string) string {
+	return os.Getenv("HOST_NAME") + "images/" + imageID
+}
+
+func NewComment(comment models.Comment) Comment {
+	return Comment{
+		ID:          comment.ID,
+		CommenterID: comment.CommenterID,
+		CommentText: comment.CommentText,
+		CommentedAt: comment.CommentedAt,
+		Mentions:    nonNil(comment.Mentions),
+	}
+}
+
+func NewPost(post models.Post) Post {
+	images := make([]string, 0, len(post.Images))
+	for _, image := range post.Images {
+		images = append(images, ImageURL(image))
+	}
+	comments := make([]Comment, 0, len(post.Comments))
+	for _, comment := range post.Comments {
+		comments = append(comments, NewComment(comment))
+	}
+
+	return Post{
+		ID:        post.ID,
+		Uploader:  post.Uploader,
+		Text:      post.Text,
+		CreatedAt: post.CreatedAt,
+		LikedBy:   nonNil(post.LikedBy),
+		Images:    images,
+		Comments:  comments,
+		Mentions:  nonNil(post.Mentions),
+	}
+}
+
+func NewPosts(posts []models.Post) []Post {
+	views := make([]Post, 0, len(posts))
+	for _, post := range posts {
+		views = append(views, NewPost(post))
+	}
+	return views
+}
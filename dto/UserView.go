@@ -0,0 +1,148 @@
+// Package dto turns models into the shapes sent to clients. Handlers never serialise a model
+// directly, so fields added to a model stay private until a view here exposes them.
+package dto
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+// PublicUser is what anyone logged in may see of a user.
+type PublicUser struct {
+	ID             primitive.ObjectID `json:"_id"`
+	Name           string             `json:"name"`
+	Handle         string             `json:"handle"`
+	Dp             string             `json:"dp"`
+	Bio            string             `json:"bio"`
+	Website        string             `json:"website"`
+	Pronouns       string             `json:"pronouns"`
+	IsPublic       bool               `json:"isPublic"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	FollowerCount  int                `json:"followerCount"`
+	FollowingCount int                `json:"followingCount"`
+}
+
+// FollowerUser is what the followers of a user may see. Emails stay with their owner.
+type FollowerUser struct {
+	PublicUser
+	Followers  []primitive.ObjectID `json:"followers"`
+	Following  []primitive.ObjectID `json:"following"`
+	IsActive   bool                 `json:"isActive"`
+	LastActive time.Time            `json:"lastActive"`
+}
+
+// SelfUser is what a user sees of their own account.
+type SelfUser struct {
+	FollowerUser
+	Email            string                 `json:"email"`
+	RequestsReceived []models.FollowRequest `json:"requestsReceived"`
+	RequestsSent     []models.FollowRequest `json:"requestsSent"`
+	TotpEnabled      bool                   `json:"totpEnabled"`
+	Roles            []string               `json:"roles"`
+	Status           string                 `json:"status"`
+	SuspendedUntil   time.Time              `json:"suspendedUntil"`
+}
+
+// AdminUser is what moderators see when reviewing an account.
+type AdminUser struct {
+	SelfUser
+	StatusReason string `json:"statusReason"`
+}
+
+// AvatarURL is the uploaded profile picture of a user, or the default one they started with.
+func AvatarURL(user models.User) string {
+	if user.AvatarID != "" {
+		return ImageURL(user.AvatarID)
+	}
+	return user.Dp
+}
+
+func NewPublicUser(user models.User) PublicUser {
+	return PublicUser{
+		ID:             user.ID,
+		Name:           user.Name,
+		Handle:         user.Handle,
+		Dp:             AvatarURL(user),
+		Bio:            user.Bio,
+		Website:        user.Website,
+		Pronouns:       user.Pronouns,
+		IsPublic:       user.IsPublic,
+		CreatedAt:      user.CreatedAt,
+		FollowerCount:  len(user.Followers),
+		FollowingCount: len(user.Following),
+	}
+}
+
+func NewFollowerUser(user models.User) FollowerUser {
+	return FollowerUser{
+		PublicUser: NewPublicUser(user),
+		Followers:  nonNil(user.Followers),
+		Following:  nonNil(user.Following),
+		IsActive:   user.IsActive,
+		LastActive: user.LastActive,
+	}
+}
+
+func NewSelfUser(user models.User) SelfUser {
+	status := user.Status
+	if status == "" {
+		status = models.UserStatusActive
+	}
+	return SelfUser{
+		FollowerUser:     NewFollowerUser(user),
+		Email:            user.Email,
+		RequestsReceived: nonNil(user.RequestsReceived),
+		RequestsSent:     nonNil(user.RequestsSent),
+		TotpEnabled:      user.TotpEnabled,
+		Roles:            helper.UserRoles(user),
+		Status:           status,
+		SuspendedUntil:   user.SuspendedUntil,
+	}
+}
+
+func NewAdminUser(user models.User) AdminUser {
+	return AdminUser{
+		SelfUser:     NewSelfUser(user),
+		StatusReason: user.StatusReason,
+	}
+}
+
+// NewUser picks the view of a user that the viewer is entitled to.
+func NewUser(user models.User, viewerID primitive.ObjectID) interface{} {
+	switch {
+	case user.ID == viewerID:
+		return NewSelfUser(user)
+	case slices.Contains(user.Followers, viewerID):
+		return NewFollowerUser(user)
+	default:
+		return NewPublicUser(user)
+	}
+}
+
+// NewUsers applies NewUser to a list of users.
+func NewUsers(users []models.User, viewerID primitive.ObjectID) []interface{} {
+	views := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		views = append(views, NewUser(user, viewerID))
+	}
+	return views
+}
+
+func NewAdminUsers(users []models.User) []AdminUser {
+	views := make([]AdminUser, 0, len(users))
+	for _, user := range users {
+		views = append(views, NewAdminUser(user))
+	}
+	return views
+}
+
+// nonNil makes empty lists encode as [] rather than null.
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
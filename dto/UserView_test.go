@@ -0,0 +1,113 @@
+package dto
+
+import (
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"socialhive/models"
+	"strings"
+	"testing"
+)
+
+// sensitiveUser has every field that must stay on the server filled in.
+func sensitiveUser(email string) models.User {
+	return models.User{
+		ID:            primitive.NewObjectID(),
+		Name:          "Jane Doe",
+		Handle:        "jane",
+		Email:         email,
+		Password:      "$2a$14$hashedpasswordvalue",
+		TotpEnabled:   true,
+		TotpSecret:    "JBSWY3DPEHPK3PXP",
+		RecoveryCodes: []string{"recovery-code-one"},
+		Roles:         []string{"admin"},
+		Status:        "active",
+	}
+}
+
+// marshalView encodes a view the way the handlers send it.
+func marshalView(t *testing.T, view interface{}) string {
+	t.Helper()
+	body, err := json.Marshal(view)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(body)
+}
+
+func assertAbsent(t *testing.T, body string, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		if strings.Contains(body, value) {
+			t.Errorf("%q found in %s", value, body)
+		}
+	}
+}
+
+func assertPresent(t *testing.T, body string, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		if !strings.Contains(body, value) {
+			t.Errorf("%q missing from %s", value, body)
+		}
+	}
+}
+
+// secrets never leave the server, whoever is looking.
+var secrets = []string{`"password"`, "hashedpasswordvalue", `"totpSecret"`, "JBSWY3DPEHPK3PXP", `"recoveryCodes"`, "recovery-code-one"}
+
+// accountFields are only shown to the account's owner.
+var accountFields = []string{`"email"`, "jane@example.com", `"roles"`, `"status"`, `"requestsReceived"`, `"requestsSent"`}
+
+func TestNewPublicUserHidesPrivateFields(t *testing.T) {
+	body := marshalView(t, NewPublicUser(sensitiveUser("jane@example.com")))
+
+	assertAbsent(t, body, secrets...)
+	assertAbsent(t, body, accountFields...)
+	assertAbsent(t, body, `"followers"`, `"following"`)
+	assertPresent(t, body, `"handle":"jane"`)
+}
+
+func TestNewUserForStranger(t *testing.T) {
+	body := marshalView(t, NewUser(sensitiveUser("jane@example.com"), primitive.NewObjectID()))
+
+	assertAbsent(t, body, secrets...)
+	assertAbsent(t, body, accountFields...)
+	assertAbsent(t, body, `"followers"`)
+}
+
+func TestNewUserForFollower(t *testing.T) {
+	followerID := primitive.NewObjectID()
+	user := sensitiveUser("jane@example.com")
+	user.Followers = []primitive.ObjectID{followerID}
+
+	body := marshalView(t, NewUser(user, followerID))
+
+	assertAbsent(t, body, secrets...)
+	assertAbsent(t, body, accountFields...)
+	assertPresent(t, body, `"followers"`)
+}
+
+func TestNewUserForSelf(t *testing.T) {
+	user := sensitiveUser("jane@example.com")
+
+	body := marshalView(t, NewUser(user, user.ID))
+
+	assertAbsent(t, body, secrets...)
+	assertPresent(t, body, `"email":"jane@example.com"`, `"totpEnabled":true`)
+}
+
+func TestNewUsersShowsOnlyTheViewersEmail(t *testing.T) {
+	viewer := sensitiveUser("viewer@example.com")
+	followed := sensitiveUser("followed@example.com")
+	followed.Followers = []primitive.ObjectID{viewer.ID}
+	stranger := sensitiveUser("stranger@example.com")
+
+	body := marshalView(t, NewUsers([]models.User{viewer, followed, stranger}, viewer.ID))
+
+	assertAbsent(t, body, secrets...)
+	assertAbsent(t, body, "followed@example.com", "stranger@example.com")
+	assertPresent(t, body, "viewer@example.com")
+	if count := strings.Count(body, `"email"`); count != 1 {
+		t.Errorf("expected only the viewer's email, found %d in %s", count, body)
+	}
+}
@@ -0,0 +1,39 @@
+package helper
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"socialhive/models"
+	"time"
+)
+
+// AccountRestricted reports whether a user is banned or serving a suspension. Suspensions end by
+// themselves once their time is up.
+func AccountRestricted(user models.User) bool {
+	switch user.Status {
+	case models.UserStatusBanned, models.UserStatusDeleted:
+		return true
+	case models.UserStatusSuspended:
+		return user.SuspendedUntil.After(time.Now())
+	}
+	return false
+}
+
+// AccountRestriction is the response body telling a restricted user why they are locked out.
+func AccountRestriction(user models.User) gin.H {
+	if user.Status == models.UserStatusDeleted {
+		return gin.H{"error": "this account has been deleted"}
+	}
+	if user.Status == models.UserStatusBanned {
+		return gin.H{"error": "this account has been banned", "reason": user.StatusReason}
+	}
+	return gin.H{"error": "this account is suspended", "reason": user.StatusReason, "until": user.SuspendedUntil}
+}
+
+// UnrestrictedAccountFilter matches the users that are neither banned nor serving a suspension.
+func UnrestrictedAccountFilter() bson.M {
+	return bson.M{"$or": []bson.M{
+		{"status": bson.M{"$nin": []string{models.UserStatusSuspended, models.UserStatusBanned, models.UserStatusDeleted}}},
+		{"status": models.UserStatusSuspended, "suspendedUntil": bson.M{"$lte": time.Now()}},
+	}}
+}
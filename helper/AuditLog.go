@@ -0,0 +1,33 @@
+package helper
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"socialhive/database"
+	"socialhive/models"
+	"time"
+)
+
+// RecordAudit stores an action taken by the caller of a request on a user, post or message.
+func RecordAudit(c *gin.Context, action string, targetType string, targetID primitive.ObjectID, details bson.M) error {
+	principal := GetPrincipal(c)
+	entry := models.AuditLog{
+		ID:         primitive.NewObjectID(),
+		ActorID:    principal.ID,
+		ActorEmail: principal.Email,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		IP:         c.ClientIP(),
+		CreatedAt:  time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	auditLogCollection := database.OpenCollection(database.Client, "audit-log-collection")
+	_, err := auditLogCollection.InsertOne(ctx, entry)
+	return err
+}
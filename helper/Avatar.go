@@ -0,0 +1,78 @@
+package helper
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+)
+
+const (
+	AvatarSize = 512
+	// larger images are refused before decoding, a small file can still expand to gigabytes
+	avatarMaxPixels = 40_000_000
+)
+
+var ErrUnsupportedImage = errors.New("the file is not a supported image (jpeg, png, gif or webp)")
+
+// CropSquare is the part of an uploaded picture to keep, in pixels of the original image. A zero
+// size keeps the largest centred square.
+type CropSquare struct {
+	X    int
+	Y    int
+	Size int
+}
+
+// ProcessAvatar crops an uploaded picture to a square, scales it to AvatarSize and re-encodes it
+// as PNG, which also drops any metadata such as the location a photo was taken at.
+func ProcessAvatar(data []byte, crop CropSquare) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > avatarMaxPixels {
+		return nil, errors.New("the image is too large")
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	bounds := source.Bounds()
+
+	if crop.Size == 0 {
+		crop.Size = min(bounds.Dx(), bounds.Dy())
+		crop.X = (bounds.Dx() - crop.Size) / 2
+		crop.Y = (bounds.Dy() - crop.Size) / 2
+	}
+	square := image.Rect(crop.X, crop.Y, crop.X+crop.Size, crop.Y+crop.Size).Add(bounds.Min)
+	if crop.X < 0 || crop.Y < 0 || crop.Size <= 0 || !square.In(bounds) {
+		return nil, errors.New("the crop square must lie within the image")
+	}
+
+	avatar := image.NewRGBA(image.Rect(0, 0, AvatarSize, AvatarSize))
+	draw.CatmullRom.Scale(avatar, avatar.Bounds(), source, square, draw.Src, nil)
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, avatar); err != nil {
+		return nil, err
+	}
+	return encoded.Bytes(), nil
+}
+
+// ReadLimited reads at most limit bytes, failing rather than truncating larger input.
+func ReadLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errors.New("the file is too large")
+	}
+	return data, nil
+}
@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"socialhive/database"
+	"time"
+)
+
+// IsBlocked reports whether either of two users has blocked the other. A block works both ways,
+// neither side sees or reaches the other.
+func IsBlocked(userID primitive.ObjectID, otherID primitive.ObjectID) (bool, error) {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"_id": userID, "blocked": otherID},
+			{"_id": otherID, "blocked": userID},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	count, err := userCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// BlockedUserIDs lists the users a user has blocked together with those who blocked them, which
+// is everyone whose content is kept from them.
+func BlockedUserIDs(userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	user, err := GetUserById(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	blockedBy, err := userCollection.Distinct(ctx, "_id", bson.M{"blocked": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := append([]primitive.ObjectID{}, user.Blocked...)
+	for _, id := range blockedBy {
+		if objectID, ok := id.(primitive.ObjectID); ok {
+			ids = append(ids, objectID)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,34 @@
+package helper
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// RenderEmail wraps plain text paragraphs in the SocialHive email layout. Paragraphs are escaped;
+// pass links separately so that they are rendered as a button.
+func RenderEmail(heading string, name string, paragraphs []string, linkText string, linkURL string) string {
+	var body strings.Builder
+	for _, paragraph := range paragraphs {
+		fmt.Fprintf(&body, `<p style="margin: 0; margin-top: 17px; font-weight: 500;">%s</p>`, html.EscapeString(paragraph))
+	}
+	if linkURL != "" {
+		fmt.Fprintf(&body, `<p style="margin: 0; margin-top: 40px;"><a href="%s" style="padding: 12px 24px; background: #ba3d4f; color: #ffffff; border-radius: 6px; text-decoration: none;">%s</a></p>`,
+			html.EscapeString(linkURL), html.EscapeString(linkText))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+  <body style="margin: 0; font-family: 'Poppins', sans-serif; background: #ffffff; font-size: 14px;">
+    <div style="max-width: 680px; margin: 0 auto; padding: 45px 30px 60px; background: #f4f7ff; color: #434343;">
+      <div style="padding: 60px 30px; background: #ffffff; border-radius: 30px; text-align: center;">
+        <h1 style="margin: 0; font-size: 24px; font-weight: 500; color: #1f1f1f;">%s</h1>
+        <p style="margin: 0; margin-top: 17px; font-size: 16px; font-weight: 500;">Hey %s,</p>
+        %s
+      </div>
+      <p style="margin: 0; margin-top: 40px; text-align: center; font-size: 16px; font-weight: 600;">SocialHive</p>
+    </div>
+  </body>
+</html>`, html.EscapeString(heading), html.EscapeString(name), body.String())
+}
@@ -1,38 +0,0 @@
-package helper
-
-import (
-	"fmt"
-	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"log"
-	"net/http"
-	"os"
-)
-
-func ExtractEmail(c *gin.Context) string {
-	tokenString, err := c.Cookie("token")
-	if err != nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
-		return ""
-	}
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-
-		// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-		return []byte(os.Getenv("SECRET_KEY")), nil
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		email := claims["sub"].(string)
-		return email
-	} else {
-		c.AbortWithStatus(http.StatusUnauthorized)
-		return ""
-	}
-}
@@ -2,9 +2,7 @@ package helper
 
 import (
 	"fmt"
-	"gopkg.in/gomail.v2"
 	"math/rand"
-	"os"
 	"socialhive/intializers"
 	"time"
 )
@@ -217,15 +215,7 @@ func GenerateAndSendOTP(userEmail string, name string) (int, error) {
 	</html>
 	`, name, otpNumber)
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", os.Getenv("EMAIL_ID"))
-	m.SetHeader("To", userEmail)
-	m.SetHeader("Subject", "Otp for sign up in SocialHive")
-	m.SetBody("text/html", htmlEmailText)
-
-	d := gomail.NewDialer("smtp.gmail.com", 587, os.Getenv("EMAIL_ID"), os.Getenv("EMAIL_PASSWORD"))
-
-	if err := d.DialAndSend(m); err != nil {
+	if err := QueueEmail(userEmail, "Otp for sign up in SocialHive", htmlEmailText); err != nil {
 		return 0, err
 	}
 	return otpNumber, nil
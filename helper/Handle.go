@@ -0,0 +1,184 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"socialhive/database"
+	"socialhive/models"
+	"strings"
+	"time"
+)
+
+// handles start with a letter so they can never be mistaken for an ObjectID or a number
+var handlePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{2,29}$`)
+
+// reservedHandles would read as part of the service or impersonate its staff.
+var reservedHandles = map[string]bool{
+	"about": true, "admin": true, "administrator": true, "api": true, "auth": true,
+	"help": true, "home": true, "images": true, "login": true, "logout": true,
+	"me": true, "moderator": true, "mod": true, "null": true, "official": true,
+	"root": true, "search": true, "security": true, "settings": true, "signup": true,
+	"socialhive": true, "staff": true, "support": true, "system": true, "undefined": true,
+	"user": true, "users": true, "www": true,
+}
+
+// NormalizeHandle returns the form handles are compared and looked up in.
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// ValidateHandle checks the charset, length and reserved-word rules for a new handle.
+func ValidateHandle(handle string) error {
+	if !handlePattern.MatchString(handle) {
+		return errors.New("handle must be 3 to 30 letters, digits or underscores and start with a letter")
+	}
+	if reservedHandles[NormalizeHandle(handle)] {
+		return errors.New("this handle is reserved")
+	}
+	return nil
+}
+
+// handleBase turns a name, or the start of an email when the name has no usable letters, into
+// a handle: folded to plain lowercase letters, words joined by underscores, and starting with a
+// letter. It leaves room for the number GenerateHandle may append.
+func handleBase(name string, email string) string {
+	for _, source := range []string{name, strings.Split(email, "@")[0]} {
+		words := []string{}
+		for _, word := range SearchTokens(source) {
+			word = strings.Map(func(r rune) rune {
+				if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+					return r
+				}
+				return -1
+			}, word)
+			if word != "" {
+				words = append(words, word)
+			}
+		}
+		base := strings.Trim(strings.Join(words, "_"), "_")
+		if base == "" {
+			continue
+		}
+		if base[0] < 'a' || base[0] > 'z' {
+			base = "user_" + base
+		}
+		if len(base) > 24 {
+			base = strings.TrimRight(base[:24], "_")
+		}
+		return base
+	}
+	return "user"
+}
+
+// GenerateHandle picks a free handle for a user who did not choose one, like those signing up
+// through a login provider. They can change it later like any other handle.
+func GenerateHandle(name string, email string) (string, error) {
+	base := handleBase(name, email)
+	for attempt := 0; attempt < 20; attempt++ {
+		handle := base
+		// short and reserved bases, and those already taken, get a number appended
+		if attempt > 0 || ValidateHandle(handle) != nil {
+			suffix, err := GenerateCode()
+			if err != nil {
+				return "", err
+			}
+			// more digits as attempts go on, for the names many people share
+			handle = base + suffix[:2+attempt/5]
+		}
+		if ValidateHandle(handle) != nil {
+			continue
+		}
+		inUse, err := HandleInUse(handle, primitive.NilObjectID)
+		if err != nil {
+			return "", err
+		}
+		if !inUse {
+			return handle, nil
+		}
+	}
+	return "", errors.New("no free handle could be found")
+}
+
+// HandleInUse reports whether a handle belongs to another user, either currently or as a handle
+// they renamed away from; old handles stay reserved so that links to them keep redirecting.
+func HandleInUse(handle string, userID primitive.ObjectID) (bool, error) {
+	normalized := NormalizeHandle(handle)
+	filter := bson.M{
+		"_id": bson.M{"$ne": userID},
+		"$or": []bson.M{
+			{"handleLower": normalized},
+			{"previousHandles": normalized},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	count, err := userCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetUserByHandle finds the user with a handle. moved reports that the handle is one the user
+// renamed away from, so that callers can redirect to the current one.
+func GetUserByHandle(handle string) (user models.User, moved bool, err error) {
+	normalized := NormalizeHandle(handle)
+	filter := bson.M{
+		"$or": []bson.M{
+			{"handleLower": normalized},
+			{"previousHandles": normalized},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	if err := userCollection.FindOne(ctx, filter).Decode(&user); err != nil {
+		return models.User{}, false, errors.New("user not found")
+	}
+	return user, user.HandleLower != normalized, nil
+}
+
+// GetUserByAddress finds the user a message is addressed to, by email or by handle with or
+// without its leading "@".
+func GetUserByAddress(address string) (models.User, error) {
+	address = strings.TrimSpace(address)
+	if strings.Contains(strings.TrimPrefix(address, "@"), "@") {
+		return GetUserByEmail(address)
+	}
+	user, _, err := GetUserByHandle(address)
+	return user, err
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@])@([a-zA-Z][a-zA-Z0-9_]{2,29})\b`)
+
+// ExtractMentions resolves the @handles in a text to the users they name. Unknown handles are
+// skipped, and so are old handles, a mention means whoever holds the handle now.
+func ExtractMentions(text string) ([]primitive.ObjectID, error) {
+	handles := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		handles = append(handles, NormalizeHandle(match[1]))
+	}
+	if len(handles) == 0 {
+		return []primitive.ObjectID{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	ids, err := userCollection.Distinct(ctx, "_id", bson.M{"handleLower": bson.M{"$in": handles}})
+	if err != nil {
+		return nil, err
+	}
+
+	mentions := []primitive.ObjectID{}
+	for _, id := range ids {
+		if objectID, ok := id.(primitive.ObjectID); ok {
+			mentions = append(mentions, objectID)
+		}
+	}
+	return mentions, nil
+}
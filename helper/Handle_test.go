@@ -0,0 +1,25 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBase(t *testing.T) {
+	tests := []struct {
+		name, email, want string
+	}{
+		{"Jane Doe", "jane@example.com", "jane_doe"},
+		{"José Álvarez", "jose@example.com", "jose_alvarez"},
+		{"", "j.doe+news@example.com", "j_doe_news"},
+		{"李雷", "li.lei@example.com", "li_lei"},
+		{"2Pac", "tupac@example.com", "user_2pac"},
+		{"", "", "user"},
+		{strings.Repeat("a", 40), "a@example.com", strings.Repeat("a", 24)},
+	}
+	for _, test := range tests {
+		if got := handleBase(test.name, test.email); got != test.want {
+			t.Errorf("handleBase(%q, %q) = %q, want %q", test.name, test.email, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package helper
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// signingKey is one key of the keyset. Retired keys only have their public half on disk and are
+// kept for verification until every token they signed has expired.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// JWK is the public part of a key as published at /.well-known/jwks.json.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// keySet signs and verifies every token; LoadKeys sets it up when the server starts.
+var keySet *KeySet
+
+// LoadKeys loads the keyset named by JWT_KEYS_DIR and JWT_SIGNING_KID.
+func LoadKeys() error {
+	ks, err := LoadKeySet(os.Getenv("JWT_KEYS_DIR"), os.Getenv("JWT_SIGNING_KID"))
+	if err != nil {
+		return err
+	}
+	keySet = ks
+	return nil
+}
+
+// LoadKeySet reads every <kid>.pem file of dir. Files may hold a PKCS#8 Ed25519 or RSA private
+// key, a PKCS#1 RSA private key, or a PKIX public key. The key named activeKID signs new tokens
+// and must be a private key.
+func LoadKeySet(dir string, activeKID string) (*KeySet, error) {
+	if dir == "" || activeKID == "" {
+		return nil, errors.New("JWT_KEYS_DIR and JWT_SIGNING_KID must be set")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{keys: make(map[string]*signingKey)}
+	for _, path := range paths {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		ks.keys[key.kid] = key
+	}
+
+	active, exists := ks.keys[activeKID]
+	if !exists || active.private == nil {
+		return nil, fmt.Errorf("no private key with kid %q in %s", activeKID, dir)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+func loadSigningKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key := &signingKey{kid: strings.TrimSuffix(filepath.Base(path), ".pem")}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// SignToken signs claims with the active key and names it in the kid header.
+func SignToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(keySet.active.method, claims)
+	token.Header["kid"] = keySet.active.kid
+	return token.SignedString(keySet.active.private)
+}
+
+// VerifyToken checks the signature of a token against the key named in its kid header, along
+// with its expiry, and returns its claims.
+func VerifyToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, exists := keySet.keys[kid]
+		if !exists {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		// Don't forget to validate the alg is what you expect:
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
+
+// PublicJWKS returns the public half of every key so that other services can verify tokens.
+func PublicJWKS() []JWK {
+	jwks := []JWK{}
+	for _, key := range keySet.keys {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,132 @@
+package helper
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"socialhive/database"
+	"socialhive/models"
+	"strings"
+	"time"
+)
+
+const (
+	// failures older than this are forgotten
+	loginFailureWindow = time.Hour
+	// failures allowed before every further attempt has to wait
+	loginFreeFailures = 3
+	loginMaxDelay     = time.Minute
+)
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginRetryAfter returns how long a login for this email from this address has to wait, or zero
+// when it may go ahead. Waits grow with every failure and become a lockout past the limit.
+func LoginRetryAfter(email string, ip string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	loginAttemptCollection := database.OpenCollection(database.Client, "login-attempt-collection")
+	cursor, err := loginAttemptCollection.Find(ctx, bson.M{"_id": bson.M{"$in": []string{accountAttemptKey(email), ipAttemptKey(ip)}}})
+	if err != nil {
+		return 0, err
+	}
+	var attempts []models.LoginAttempt
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, attempt := range attempts {
+		if attempt.LockedUntil.After(now) {
+			wait = max(wait, attempt.LockedUntil.Sub(now))
+			continue
+		}
+		if now.Sub(attempt.LastFailureAt) > loginFailureWindow {
+			continue
+		}
+		if next := attempt.LastFailureAt.Add(loginDelay(attempt.Failures)); next.After(now) {
+			wait = max(wait, next.Sub(now))
+		}
+	}
+	return wait, nil
+}
+
+// loginDelay doubles the wait for every failure past the free ones.
+func loginDelay(failures int) time.Duration {
+	if failures <= loginFreeFailures {
+		return 0
+	}
+	if failures-loginFreeFailures > 6 {
+		return loginMaxDelay
+	}
+	return min(time.Second<<(failures-loginFreeFailures), loginMaxDelay)
+}
+
+// RecordLoginFailure counts a failed login against both the account and the address. It reports
+// whether this failure locked the account.
+func RecordLoginFailure(email string, ip string) (bool, error) {
+	_, err := recordFailure(ipAttemptKey(ip), GetEnvInt("LOGIN_MAX_IP_FAILURES", 50))
+	if err != nil {
+		return false, err
+	}
+	return recordFailure(accountAttemptKey(email), GetEnvInt("LOGIN_MAX_ACCOUNT_FAILURES", 10))
+}
+
+func recordFailure(key string, limit int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	loginAttemptCollection := database.OpenCollection(database.Client, "login-attempt-collection")
+	now := time.Now()
+
+	// failures outside the window are forgotten before this one is counted
+	stale := bson.M{"_id": key, "lastFailureAt": bson.M{"$lt": now.Add(-loginFailureWindow)}}
+	if _, err := loginAttemptCollection.UpdateOne(ctx, stale, bson.M{"$set": bson.M{"failures": 0}}); err != nil {
+		return false, err
+	}
+
+	// counted in a single update, so that failures arriving at the same time are all counted
+	var attempt models.LoginAttempt
+	update := bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"lastFailureAt": now}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := loginAttemptCollection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempt); err != nil {
+		return false, err
+	}
+	if attempt.Failures < limit || attempt.LockedUntil.After(now) {
+		return false, nil
+	}
+
+	// only one of the failures crossing the limit locks, the count starts over once the lockout ends
+	filter := bson.M{
+		"_id":         key,
+		"failures":    bson.M{"$gte": limit},
+		"lockedUntil": bson.M{"$not": bson.M{"$gt": now}},
+	}
+	lock := bson.M{"$set": bson.M{
+		"lockedUntil": now.Add(GetEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)),
+		"failures":    0,
+	}}
+	result, err := loginAttemptCollection.UpdateOne(ctx, filter, lock)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// ClearLoginFailures forgets failures of an account after a successful login or an admin unlock.
+func ClearLoginFailures(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	loginAttemptCollection := database.OpenCollection(database.Client, "login-attempt-collection")
+	_, err := loginAttemptCollection.DeleteOne(ctx, bson.M{"_id": accountAttemptKey(email)})
+	return err
+}
@@ -0,0 +1,44 @@
+package helper
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/gomail.v2"
+	"os"
+	"socialhive/database"
+	"socialhive/models"
+	"time"
+)
+
+// QueueEmail stores an email in the outbox; the outbox worker delivers it in the background.
+func QueueEmail(to string, subject string, body string) error {
+	now := time.Now()
+	message := models.OutboxMessage{
+		ID:            primitive.NewObjectID(),
+		To:            to,
+		Subject:       subject,
+		Body:          body,
+		Status:        models.OutboxStatusPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	outboxCollection := database.OpenCollection(database.Client, "outbox")
+	_, err := outboxCollection.InsertOne(ctx, message)
+	return err
+}
+
+// SendEmail delivers an email over SMTP right away. Handlers should use QueueEmail instead.
+func SendEmail(to string, subject string, body string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", os.Getenv("EMAIL_ID"))
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer("smtp.gmail.com", 587, os.Getenv("EMAIL_ID"), os.Getenv("EMAIL_PASSWORD"))
+
+	return d.DialAndSend(m)
+}
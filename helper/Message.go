@@ -0,0 +1,46 @@
+package helper
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"socialhive/database"
+	"socialhive/models"
+	"time"
+)
+
+// NewMessageParticipant copies what a message keeps of its sender or recipient.
+func NewMessageParticipant(user models.User) models.MessageParticipant {
+	return models.MessageParticipant{
+		ID:     user.ID,
+		Handle: user.Handle,
+		Email:  user.Email,
+	}
+}
+
+// StripMessageParticipants trims the senders and recipients of messages stored as whole user
+// documents, which copied password hashes and two-factor secrets into every message.
+func StripMessageParticipants() error {
+	participant := func(field string) bson.M {
+		return bson.M{
+			"_id":    "$" + field + "._id",
+			"handle": bson.M{"$ifNull": bson.A{"$" + field + ".handle", ""}},
+			"email":  bson.M{"$ifNull": bson.A{"$" + field + ".email", ""}},
+		}
+	}
+	filter := bson.M{"$or": []bson.M{
+		{"sender.name": bson.M{"$exists": true}},
+		{"recipient.name": bson.M{"$exists": true}},
+		{"sender.password": bson.M{"$exists": true}},
+		{"recipient.password": bson.M{"$exists": true}},
+	}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"sender": participant("sender"), "recipient": participant("recipient")}}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	msgCollection := database.OpenCollection(database.Client, "message-collection")
+	_, err := msgCollection.UpdateMany(ctx, filter, update)
+	return err
+}
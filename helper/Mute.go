@@ -0,0 +1,72 @@
+package helper
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"socialhive/database"
+	"socialhive/models"
+	"time"
+)
+
+// MuteActive reports whether a mute is still in force.
+func MuteActive(mute models.MutedUser) bool {
+	return mute.Until.IsZero() || mute.Until.After(time.Now())
+}
+
+// MutedUserIDs lists the accounts a user currently has muted.
+func MutedUserIDs(user models.User) []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, mute := range user.MutedUsers {
+		if MuteActive(mute) {
+			ids = append(ids, mute.UserID)
+		}
+	}
+	return ids
+}
+
+// MutedByUserIDs lists the users who currently have a user muted.
+func MutedByUserIDs(userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	filter := bson.M{"mutedUsers": bson.M{"$elemMatch": bson.M{
+		"userId": userID,
+		"$or":    []bson.M{{"until": time.Time{}}, {"until": bson.M{"$gt": time.Now()}}},
+	}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	values, err := userCollection.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []primitive.ObjectID{}
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// MutedWordsMatcher builds a matcher for the muted words of a user. Phrases match whole words
+// regardless of case and accents, so muting "cat" leaves "category" alone but hides "#cat".
+func MutedWordsMatcher(words []models.MutedWord) func(text string) bool {
+	patterns := []*regexp.Regexp{}
+	for _, word := range words {
+		pattern := `(?:^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(FoldSearchText(word.Phrase)) + `(?:$|[^\p{L}\p{N}_])`
+		patterns = append(patterns, regexp.MustCompile(pattern))
+	}
+	return func(text string) bool {
+		if len(patterns) == 0 {
+			return false
+		}
+		folded := FoldSearchText(text)
+		for _, pattern := range patterns {
+			if pattern.MatchString(folded) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"net/http"
+	"socialhive/intializers"
+	"sync"
+	"time"
+)
+
+var ErrUnknownOIDCProvider = errors.New("unknown login provider")
+
+var oidcConfigs = intializers.LoadConfig().OIDCProviders
+
+// OIDCProvider bundles what a login through one OpenID Connect provider needs.
+type OIDCProvider struct {
+	Name     string
+	OAuth2   oauth2.Config
+	Verifier *oidc.IDTokenVerifier
+}
+
+var (
+	oidcProviders    = map[string]*OIDCProvider{}
+	oidcProvidersMut sync.Mutex
+)
+
+// GetOIDCProvider returns a configured provider. Discovery runs on first use rather than at
+// startup, so an unreachable provider only breaks its own logins.
+func GetOIDCProvider(name string) (*OIDCProvider, error) {
+	oidcProvidersMut.Lock()
+	defer oidcProvidersMut.Unlock()
+
+	if provider, ok := oidcProviders[name]; ok {
+		return provider, nil
+	}
+
+	config, ok := oidcConfigs[name]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	// the key set fetched later by the verifier keeps this context, so it must outlive the request
+	ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: 10 * time.Second})
+	provider, err := NewOIDCProvider(ctx, name, config)
+	if err != nil {
+		return nil, err
+	}
+	oidcProviders[name] = provider
+	return provider, nil
+}
+
+// NewOIDCProvider discovers the endpoints and keys of a provider from its issuer.
+func NewOIDCProvider(ctx context.Context, name string, config intializers.OIDCProviderConfig) (*OIDCProvider, error) {
+	discovered, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &OIDCProvider{
+		Name: name,
+		OAuth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			Endpoint:     discovered.Endpoint(),
+			RedirectURL:  config.RedirectURL,
+			Scopes:       scopes,
+		},
+		Verifier: discovered.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}, nil
+}
+
+// OIDCIdentity is who logged in at a provider.
+type OIDCIdentity struct {
+	Subject       string
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// Identify finishes a login at the provider. The code is redeemed with the PKCE verifier, and
+// the ID token it brings must be signed by the provider, issued to this client, unexpired and
+// carry the nonce the login was started with.
+func (provider *OIDCProvider) Identify(ctx context.Context, code string, codeVerifier string, nonce string) (OIDCIdentity, error) {
+	token, err := provider.OAuth2.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return OIDCIdentity{}, err
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := provider.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OIDCIdentity{}, err
+	}
+	if idToken.Nonce != nonce {
+		return OIDCIdentity{}, errors.New("the ID token was issued for another login")
+	}
+
+	var identity OIDCIdentity
+	if err := idToken.Claims(&identity); err != nil {
+		return OIDCIdentity{}, err
+	}
+	identity.Subject = idToken.Subject
+	return identity, nil
+}
+
+// OIDCProviderNames lists the configured providers for the login page.
+func OIDCProviderNames() map[string]string {
+	names := map[string]string{}
+	for name, config := range oidcConfigs {
+		names[name] = config.DisplayName
+	}
+	return names
+}
@@ -0,0 +1,181 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"socialhive/intializers"
+	"testing"
+	"time"
+)
+
+const (
+	mockClientID     = "socialhive-test"
+	mockCode         = "authorization-code"
+	mockCodeVerifier = "code-verifier-of-this-login"
+	mockNonce        = "nonce-of-this-login"
+)
+
+// mockIssuer is an OpenID Connect provider serving discovery, its key set and a token endpoint
+// that hands out the claims of idToken, signed by signer.
+type mockIssuer struct {
+	server  *httptest.Server
+	idToken func(issuer string) jwt.MapClaims
+	signer  *rsa.PrivateKey
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &mockIssuer{signer: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                issuer.server.URL,
+			"authorization_endpoint":                issuer.server.URL + "/authorize",
+			"token_endpoint":                        issuer.server.URL + "/token",
+			"jwks_uri":                              issuer.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []JWK{{
+			Kty: "RSA",
+			Kid: "mock",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		// a code is only redeemed together with the verifier of the login that asked for it
+		if r.FormValue("code") != mockCode || r.FormValue("code_verifier") != mockCodeVerifier {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.idToken(issuer.server.URL))
+		token.Header["kid"] = "mock"
+		signed, err := token.SignedString(issuer.signer)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	issuer.idToken = func(iss string) jwt.MapClaims {
+		return validIDToken(iss)
+	}
+	return issuer
+}
+
+func validIDToken(issuer string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            issuer,
+		"sub":            "subject-1",
+		"aud":            mockClientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          mockNonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+}
+
+func (issuer *mockIssuer) provider(t *testing.T) *OIDCProvider {
+	t.Helper()
+	provider, err := NewOIDCProvider(context.Background(), "mock", intializers.OIDCProviderConfig{
+		Issuer:      issuer.server.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://localhost/auth/oidc/mock/callback",
+	})
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+	return provider
+}
+
+func TestOIDCIdentifyAcceptsValidLogin(t *testing.T) {
+	issuer := newMockIssuer(t)
+
+	identity, err := issuer.provider(t).Identify(context.Background(), mockCode, mockCodeVerifier, mockNonce)
+	if err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "jane@example.com" || !identity.EmailVerified || identity.Name != "Jane Doe" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCIdentifyRejectsWrongCodeVerifier(t *testing.T) {
+	issuer := newMockIssuer(t)
+
+	if _, err := issuer.provider(t).Identify(context.Background(), mockCode, "another-verifier", mockNonce); err == nil {
+		t.Error("expected the exchange to fail without the login's verifier")
+	}
+}
+
+func TestOIDCIdentifyRejectsInvalidIDTokens(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(claims jwt.MapClaims)
+		signer *rsa.PrivateKey
+		nonce  string
+	}{
+		{name: "nonce of another login", nonce: "another-nonce"},
+		{name: "issued to another client", change: func(claims jwt.MapClaims) { claims["aud"] = "another-client" }},
+		{name: "issued by another issuer", change: func(claims jwt.MapClaims) { claims["iss"] = "https://issuer.example.com" }},
+		{name: "expired", change: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "signed by an unknown key", signer: otherKey},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issuer := newMockIssuer(t)
+			issuer.idToken = func(iss string) jwt.MapClaims {
+				claims := validIDToken(iss)
+				if test.change != nil {
+					test.change(claims)
+				}
+				return claims
+			}
+			if test.signer != nil {
+				issuer.signer = test.signer
+			}
+			nonce := mockNonce
+			if test.nonce != "" {
+				nonce = test.nonce
+			}
+
+			if _, err := issuer.provider(t).Identify(context.Background(), mockCode, mockCodeVerifier, nonce); err == nil {
+				t.Error("expected the ID token to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// PasswordHasher produces and checks one encoding of password hashes. Hashes carry their own
+// parameters, so hashes made with older settings keep verifying after the settings change.
+type PasswordHasher interface {
+	// Recognizes reports whether an encoded hash was made by this hasher.
+	Recognizes(encoded string) bool
+	Hash(password string) (string, error)
+	Verify(encoded string, password string) (bool, error)
+	// NeedsRehash reports whether an encoded hash uses weaker settings than the hasher's own.
+	NeedsRehash(encoded string) bool
+}
+
+// passwordHashers are tried in order when verifying; the first one hashes new passwords.
+var passwordHashers = []PasswordHasher{
+	Argon2idHasher{
+		Time:    uint32(GetEnvInt("PASSWORD_ARGON2_TIME", 3)),
+		Memory:  uint32(GetEnvInt("PASSWORD_ARGON2_MEMORY_KIB", 64*1024)),
+		Threads: uint8(GetEnvInt("PASSWORD_ARGON2_THREADS", 2)),
+		KeyLen:  32,
+		SaltLen: 16,
+	},
+	// hashes from before argon2id, upgraded as their owners log in
+	BcryptHasher{Cost: bcrypt.DefaultCost},
+}
+
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// HashPassword hashes a password with the preferred hasher.
+func HashPassword(password string) (string, error) {
+	return passwordHashers[0].Hash(password)
+}
+
+// VerifyPassword checks a password against its stored hash. When it matches but the hash is not
+// up to date with the preferred hasher, needsRehash asks the caller to store a fresh hash.
+func VerifyPassword(encoded string, password string) (ok bool, needsRehash bool) {
+	for i, hasher := range passwordHashers {
+		if !hasher.Recognizes(encoded) {
+			continue
+		}
+		ok, err := hasher.Verify(encoded, password)
+		if err != nil || !ok {
+			return false, false
+		}
+		return true, i > 0 || hasher.NeedsRehash(encoded)
+	}
+	return false, false
+}
+
+// Argon2idHasher stores hashes in the PHC string format used by the reference implementation:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+var argon2Encoding = base64.RawStdEncoding
+
+func (h Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Time, h.Threads,
+		argon2Encoding.EncodeToString(salt), argon2Encoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(encoded string, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Time != h.Time || params.Memory != h.Memory || params.Threads != h.Threads ||
+		uint32(len(key)) != h.KeyLen || uint32(len(salt)) != h.SaltLen
+}
+
+func decodeArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	var params Argon2idHasher
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	salt, err := argon2Encoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	key, err := argon2Encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	return params, salt, key, nil
+}
+
+// BcryptHasher handles the hashes every account had before argon2id was introduced.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+func (h BcryptHasher) Verify(encoded string, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.Cost
+}
@@ -0,0 +1,59 @@
+package helper
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed banned-passwords.txt
+var bannedPasswordList string
+
+var bannedPasswords = loadBannedPasswords()
+
+func loadBannedPasswords() map[string]bool {
+	banned := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(bannedPasswordList))
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" && !strings.HasPrefix(password, "#") {
+			banned[strings.ToLower(password)] = true
+		}
+	}
+	return banned
+}
+
+var (
+	passwordMinLength = GetEnvInt("PASSWORD_MIN_LENGTH", 10)
+	// very long inputs would only make hashing expensive
+	passwordMaxLength = GetEnvInt("PASSWORD_MAX_LENGTH", 128)
+)
+
+// CheckPasswordPolicy tells whether a new password is acceptable. Personal details such as the
+// user's name and email are passed in so that they cannot be used as the password.
+func CheckPasswordPolicy(password string, personal ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < passwordMinLength {
+		return fmt.Errorf("password must be at least %d characters long", passwordMinLength)
+	}
+	if length > passwordMaxLength {
+		return fmt.Errorf("password must be at most %d characters long", passwordMaxLength)
+	}
+
+	lowered := strings.ToLower(password)
+	if bannedPasswords[lowered] {
+		return errors.New("this password is too common, choose another one")
+	}
+	for _, detail := range personal {
+		detail = strings.ToLower(strings.TrimSpace(detail))
+		if detail == "" {
+			continue
+		}
+		if lowered == detail || lowered == strings.Split(detail, "@")[0] {
+			return errors.New("password must not be your name or email")
+		}
+	}
+	return nil
+}
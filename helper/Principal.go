@@ -0,0 +1,63 @@
+package helper
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"socialhive/models"
+)
+
+const (
+	PrincipalKey = "principal"
+
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every role that can be granted, from least to most powerful.
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// Principal is the authenticated caller of a request, set on the context by RequireAuth.
+type Principal struct {
+	ID        primitive.ObjectID
+	Email     string
+	Roles     []string
+	SessionID primitive.ObjectID
+}
+
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// GetPrincipal returns the caller of a request. It may only be used behind RequireAuth.
+func GetPrincipal(c *gin.Context) Principal {
+	return c.MustGet(PrincipalKey).(Principal)
+}
+
+// NewPrincipal builds the principal for a user authenticated through the given session.
+func NewPrincipal(user models.User, sessionID primitive.ObjectID) Principal {
+	return Principal{
+		ID:        user.ID,
+		Email:     user.Email,
+		Roles:     UserRoles(user),
+		SessionID: sessionID,
+	}
+}
+
+// UserRoles returns the roles of a user. Every user has the user role, whether stored or not.
+func UserRoles(user models.User) []string {
+	roles := []string{RoleUser}
+	for _, role := range user.Roles {
+		if role != RoleUser && slices.Contains(Roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// IsStaff reports whether a user moderates others, which protects them from other moderators.
+func IsStaff(user models.User) bool {
+	roles := UserRoles(user)
+	return slices.Contains(roles, RoleModerator) || slices.Contains(roles, RoleAdmin)
+}
@@ -0,0 +1,101 @@
+package helper
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"socialhive/database"
+	"socialhive/models"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FoldSearchText lowercases a text and strips its accents, so that "José" is found by "jose".
+func FoldSearchText(text string) string {
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(folder, text)
+	if err != nil {
+		folded = text
+	}
+	return strings.ToLower(folded)
+}
+
+// SearchTokens splits a text into the folded words it is searched by. Underscores are kept so
+// that handles stay one word.
+func SearchTokens(text string) []string {
+	return strings.FieldsFunc(FoldSearchText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// SearchTerms is what a user is indexed under for search: every word of their name plus their
+// handle. It has to be recomputed whenever either of them changes.
+func SearchTerms(name string, handle string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, term := range append(SearchTokens(name), NormalizeHandle(handle)) {
+		if term != "" && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// EditDistance counts the insertions, deletions, substitutions and swaps of neighbouring
+// characters that turn one word into the other.
+func EditDistance(a string, b string) int {
+	x, y := []rune(a), []rune(b)
+	previous := make([]int, len(y)+1)
+	current := make([]int, len(y)+1)
+	beforePrevious := make([]int, len(y)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		current[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && x[i-1] == y[j-2] && x[i-2] == y[j-1] {
+				current[j] = min(current[j], beforePrevious[j-2]+1)
+			}
+		}
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+	return previous[len(y)]
+}
+
+// BackfillSearchTerms indexes the users that were created before search existed.
+func BackfillSearchTerms() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	filter := bson.M{"searchTerms": bson.M{"$not": bson.M{"$type": "array"}}}
+	opts := options.Find().SetProjection(bson.M{"name": 1, "handle": 1})
+	cursor, err := userCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		update := bson.M{"$set": bson.M{"searchTerms": SearchTerms(user.Name, user.Handle)}}
+		if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
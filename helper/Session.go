@@ -0,0 +1,141 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"socialhive/database"
+	"socialhive/models"
+	"strings"
+	"time"
+)
+
+const SessionTTL = time.Hour * 24 * 30
+
+// CreateSession records a new login of a user and returns the signed token for it.
+func CreateSession(c *gin.Context, user models.User) (string, models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionTTL),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sessionCollection := database.OpenCollection(database.Client, "session-collection")
+	if _, err := sessionCollection.InsertOne(ctx, session); err != nil {
+		return "", models.Session{}, err
+	}
+
+	// the subject is the user id, which unlike the email never changes
+	tokenString, err := SignToken(jwt.MapClaims{
+		"sub": user.ID.Hex(),
+		"sid": session.ID.Hex(),
+		"exp": session.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", models.Session{}, err
+	}
+	return tokenString, session, nil
+}
+
+// ParseSessionToken verifies a token and returns the ids of its user and session.
+func ParseSessionToken(tokenString string) (primitive.ObjectID, primitive.ObjectID, error) {
+	claims, err := VerifyToken(tokenString)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	sessionID, _ := claims["sid"].(string)
+
+	userObjectID, err := primitive.ObjectIDFromHex(subject)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid token subject")
+	}
+	sessionObjectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid token session")
+	}
+	return userObjectID, sessionObjectID, nil
+}
+
+// GetActiveSession returns a session of the user that is neither expired nor revoked.
+func GetActiveSession(sessionID primitive.ObjectID, userID primitive.ObjectID) (models.Session, error) {
+	filter := bson.M{
+		"_id":       sessionID,
+		"userId":    userID,
+		"expiresAt": bson.M{"$gt": time.Now()},
+		"revokedAt": time.Time{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sessionCollection := database.OpenCollection(database.Client, "session-collection")
+
+	var session models.Session
+	if err := sessionCollection.FindOne(ctx, filter).Decode(&session); err != nil {
+		return models.Session{}, errors.New("session not found")
+	}
+	return session, nil
+}
+
+// RevokeSessions ends sessions of a user matching filter, e.g. a single session id.
+func RevokeSessions(userID primitive.ObjectID, filter bson.M) error {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	filter["userId"] = userID
+	filter["revokedAt"] = time.Time{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sessionCollection := database.OpenCollection(database.Client, "session-collection")
+	_, err := sessionCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	return err
+}
+
+// TokenFromRequest returns the session token of a request, read from an "Authorization: Bearer"
+// header for non-browser clients or from the token cookie otherwise.
+func TokenFromRequest(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+
+	tokenString, err := c.Cookie("token")
+	if err != nil {
+		return ""
+	}
+	return tokenString
+}
+
+// reauthWindow is how long after logging in a session may do sensitive things without being
+// asked for the password again.
+var reauthWindow = GetEnvDuration("REAUTH_WINDOW", 5*time.Minute)
+
+// RecentlyAuthenticated reports whether the session was created by a login within the
+// re-authentication window.
+func RecentlyAuthenticated(sessionID primitive.ObjectID, userID primitive.ObjectID) bool {
+	session, err := GetActiveSession(sessionID, userID)
+	if err != nil {
+		return false
+	}
+	return WithinReauthWindow(session.CreatedAt)
+}
+
+// WithinReauthWindow reports whether a login or password check made at the given time still
+// counts as re-authentication.
+func WithinReauthWindow(at time.Time) bool {
+	return time.Since(at) < reauthWindow
+}
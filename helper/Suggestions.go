@@ -0,0 +1,157 @@
+package helper
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"math"
+	"socialhive/database"
+	"socialhive/models"
+	"sort"
+	"time"
+)
+
+const (
+	// how many friends of friends are weighed, the ones with the most mutual connections first
+	suggestionCandidateLimit = 300
+	suggestionMax            = 100
+	suggestionMutualSample   = 3
+)
+
+// SuggestionExclusions lists the accounts never suggested to a user: themselves, whoever they
+// already follow or asked to follow, both sides of their blocks and the suggestions they
+// dismissed.
+func SuggestionExclusions(user models.User) ([]primitive.ObjectID, error) {
+	blocked, err := BlockedUserIDs(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	excluded := append([]primitive.ObjectID{user.ID}, user.Following...)
+	for _, followRequest := range user.RequestsSent {
+		excluded = append(excluded, followRequest.To)
+	}
+	excluded = append(excluded, blocked...)
+	return append(excluded, user.HiddenSuggestions...), nil
+}
+
+// suggestionActivityWeight favours accounts in recent use; one that has been away for a few weeks
+// counts for little more than half as much.
+func suggestionActivityWeight(user models.User) float64 {
+	if user.IsActive {
+		return 1
+	}
+	days := time.Since(user.LastActive).Hours() / 24
+	return 0.5 + 0.5/(1+math.Max(days, 0)/7)
+}
+
+// ComputeSuggestions ranks the accounts followed by the people a user follows by how many of them
+// follow each one, weighted by how recently the account was active.
+func ComputeSuggestions(user models.User) ([]models.Suggestion, error) {
+	suggestions := []models.Suggestion{}
+	if len(user.Following) == 0 {
+		return suggestions, nil
+	}
+	excluded, err := SuggestionExclusions(user)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+
+	// counting is left to the database, the graph around a user can be far too big to load
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": bson.M{"$in": user.Following}}}},
+		{{Key: "$project", Value: bson.M{"following": 1}}},
+		{{Key: "$unwind", Value: "$following"}},
+		{{Key: "$match", Value: bson.M{"following": bson.M{"$nin": excluded}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$following",
+			"mutuals": bson.M{"$sum": 1},
+			"via":     bson.M{"$push": "$_id"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "mutuals", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: suggestionCandidateLimit}},
+		{{Key: "$project", Value: bson.M{"mutuals": 1, "via": bson.M{"$slice": bson.A{"$via", suggestionMutualSample}}}}},
+	}
+	cursor, err := userCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	type candidate struct {
+		ID      primitive.ObjectID   `bson:"_id"`
+		Mutuals int                  `bson:"mutuals"`
+		Via     []primitive.ObjectID `bson:"via"`
+	}
+	candidates := []candidate{}
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return suggestions, nil
+	}
+
+	ids := []primitive.ObjectID{}
+	for _, c := range candidates {
+		ids = append(ids, c.ID)
+	}
+	filter := bson.M{"$and": []bson.M{{"_id": bson.M{"$in": ids}}, UnrestrictedAccountFilter()}}
+	opts := options.Find().SetProjection(bson.M{"isActive": 1, "lastActive": 1})
+	cursor, err = userCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	weights := map[primitive.ObjectID]float64{}
+	for _, candidateUser := range users {
+		weights[candidateUser.ID] = suggestionActivityWeight(candidateUser)
+	}
+
+	for _, c := range candidates {
+		weight, ok := weights[c.ID]
+		if !ok {
+			// suspended, banned or gone
+			continue
+		}
+		suggestions = append(suggestions, models.Suggestion{
+			UserID:      c.ID,
+			MutualCount: c.Mutuals,
+			MutualIDs:   c.Via,
+			Score:       float64(c.Mutuals) * weight,
+		})
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	if len(suggestions) > suggestionMax {
+		suggestions = suggestions[:suggestionMax]
+	}
+	return suggestions, nil
+}
+
+// RefreshSuggestions computes and stores the suggestions of a user.
+func RefreshSuggestions(user models.User) (models.SuggestionSet, error) {
+	suggestions, err := ComputeSuggestions(user)
+	if err != nil {
+		return models.SuggestionSet{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	suggestionCollection := database.OpenCollection(database.Client, "suggestion-collection")
+
+	var set models.SuggestionSet
+	update := bson.M{
+		"$set":         bson.M{"suggestions": suggestions, "computedAt": time.Now()},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err = suggestionCollection.FindOneAndUpdate(ctx, bson.M{"userId": user.ID}, update, opts).Decode(&set)
+	return set, err
+}
@@ -36,3 +36,38 @@ func GetUserById(id primitive.ObjectID) (models.User, error) {
 	}
 	return user, nil
 }
+
+// EmailInUse reports whether an address belongs to an account, either currently or as an
+// address it changed away from. Previous addresses stay reserved so that old sessions and
+// revert links keep resolving to the right account.
+func EmailInUse(email string) (bool, error) {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"email": email},
+			{"previousEmails": email},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	count, err := userCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// BootstrapAdmins grants the admin role to the accounts listed in ADMIN_EMAILS, so that a fresh
+// installation has someone who can hand out roles through the admin API.
+func BootstrapAdmins() error {
+	emails := GetEnvList("ADMIN_EMAILS")
+	if len(emails) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	_, err := userCollection.UpdateMany(ctx, bson.M{"email": bson.M{"$in": emails}}, bson.M{"$addToSet": bson.M{"roles": RoleAdmin}})
+	return err
+}
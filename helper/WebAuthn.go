@@ -0,0 +1,101 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"os"
+	"socialhive/database"
+	"socialhive/models"
+	"time"
+)
+
+// WebAuthn is nil when WEBAUTHN_RP_ID is not set, which turns passkeys off.
+var WebAuthn *webauthn.WebAuthn = loadWebAuthn()
+
+func loadWebAuthn() *webauthn.WebAuthn {
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		return nil
+	}
+
+	rpName := os.Getenv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = "SocialHive"
+	}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     GetEnvList("WEBAUTHN_RP_ORIGINS"),
+	})
+	if err != nil {
+		log.Fatal("Error configuring webauthn: ", err)
+	}
+	return w
+}
+
+// WebAuthnUser adapts a user and their passkeys to what the webauthn library expects.
+type WebAuthnUser struct {
+	User     models.User
+	Passkeys []models.Passkey
+}
+
+func (u WebAuthnUser) WebAuthnID() []byte {
+	return u.User.ID[:]
+}
+
+func (u WebAuthnUser) WebAuthnName() string {
+	return u.User.Email
+}
+
+func (u WebAuthnUser) WebAuthnDisplayName() string {
+	return u.User.Name
+}
+
+func (u WebAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.Passkeys))
+	for i, passkey := range u.Passkeys {
+		credentials[i] = passkey.Credential
+	}
+	return credentials
+}
+
+// GetWebAuthnUser loads a user together with their registered passkeys.
+func GetWebAuthnUser(userID primitive.ObjectID) (WebAuthnUser, error) {
+	user, err := GetUserById(userID)
+	if err != nil {
+		return WebAuthnUser{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	passkeyCollection := database.OpenCollection(database.Client, "passkey-collection")
+	cursor, err := passkeyCollection.Find(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return WebAuthnUser{}, err
+	}
+	var passkeys []models.Passkey
+	if err := cursor.All(ctx, &passkeys); err != nil {
+		return WebAuthnUser{}, err
+	}
+
+	return WebAuthnUser{User: user, Passkeys: passkeys}, nil
+}
+
+// DiscoverWebAuthnUser finds the owner of a passkey from the user handle its authenticator returned.
+func DiscoverWebAuthnUser(rawID []byte, userHandle []byte) (webauthn.User, error) {
+	if len(userHandle) != len(primitive.ObjectID{}) {
+		return nil, errors.New("unknown user handle")
+	}
+	var userID primitive.ObjectID
+	copy(userID[:], userHandle)
+	return GetWebAuthnUser(userID)
+}
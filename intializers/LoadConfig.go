@@ -0,0 +1,54 @@
+package intializers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// OIDCProviderConfig describes an OpenID Connect provider users can log in with. The client
+// secret may be given directly or, better, as the name of an environment variable holding it.
+type OIDCProviderConfig struct {
+	DisplayName     string   `json:"displayName"`
+	Issuer          string   `json:"issuer"`
+	ClientID        string   `json:"clientId"`
+	ClientSecret    string   `json:"clientSecret"`
+	ClientSecretEnv string   `json:"clientSecretEnv"`
+	RedirectURL     string   `json:"redirectUrl"`
+	Scopes          []string `json:"scopes"`
+}
+
+type Config struct {
+	OIDCProviders map[string]OIDCProviderConfig `json:"oidcProviders"`
+}
+
+// LoadConfig reads the JSON file named by CONFIG_FILE, or config.json by default. A missing file
+// yields an empty configuration, so optional features stay off.
+func LoadConfig() Config {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		path = "config.json"
+	}
+
+	var config Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config
+	}
+	if err != nil {
+		log.Fatal("Error reading config file: ", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Fatal("Error parsing config file: ", err)
+	}
+
+	for name, provider := range config.OIDCProviders {
+		if provider.ClientSecretEnv != "" {
+			provider.ClientSecret = os.Getenv(provider.ClientSecretEnv)
+			config.OIDCProviders[name] = provider
+		}
+	}
+	return config
+}
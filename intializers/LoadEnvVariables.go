@@ -1,13 +1,17 @@
 package intializers
 
 import (
+	"errors"
 	"github.com/joho/godotenv"
+	"io/fs"
 	"log"
 )
 
+// LoadEnvVariables reads the .env file. Without one the variables are taken from the environment
+// as they are, which is also what tests run with.
 func LoadEnvVariables() {
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatal("Error loading .env file")
 	}
 }
@@ -5,8 +5,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"log"
 	"os"
+	"socialhive/database"
+	"socialhive/helper"
 	"socialhive/intializers"
 	"socialhive/routes"
+	"socialhive/workers"
 )
 
 func init() {
@@ -14,11 +17,31 @@ func init() {
 }
 
 func main() {
+	if err := helper.LoadKeys(); err != nil {
+		log.Fatal("Error loading jwt keys: ", err)
+	}
+	database.EnsureIndexes()
+	if err := helper.BootstrapAdmins(); err != nil {
+		log.Fatal(err)
+	}
+	if err := helper.BackfillSearchTerms(); err != nil {
+		log.Fatal(err)
+	}
+	if err := helper.StripMessageParticipants(); err != nil {
+		log.Fatal(err)
+	}
+
+	// background workers
+	go workers.RunOutboxWorker()
+	go workers.RunAccountDeletionWorker()
+	go workers.RunDataExportWorker()
+	go workers.RunSuggestionWorker()
+
 	router := gin.Default()
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://127.0.0.1:5500", "http://localhost:3000"}, // Add allowed origins
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Content-Type", "Authorization"},
 		AllowCredentials: true, // Allow cookies if needed
 	}))
@@ -31,6 +54,8 @@ func main() {
 	routes.MessageRouter(router)
 	routes.PostRouter(router)
 	routes.ConnectionRouter(router)
+	routes.AccountRouter(router)
+	routes.AdminRouter(router)
 
 	PORT := os.Getenv("PORT")
 
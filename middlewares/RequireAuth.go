@@ -1,70 +1,57 @@
 package middlewares
 
 import (
-	"context"
-	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
-	"os"
-	"socialhive/database"
-	"socialhive/models"
-	"time"
+	"socialhive/helper"
 )
 
 func RequireAuth(c *gin.Context) {
-	tokenString, err := c.Cookie("token")
-	if err != nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
+	// cookie for browsers, bearer header for other clients
+	tokenString := helper.TokenFromRequest(c)
 
 	// when user is logged out
 	if tokenString == "" {
 		c.AbortWithStatus(http.StatusUnauthorized)
-		c.Abort()
+		return
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-
-		// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-		return []byte(os.Getenv("SECRET_KEY")), nil
-	})
+	// verifies the signature and the expiry
+	userID, sessionID, err := helper.ParseSessionToken(tokenString)
 	if err != nil {
 		c.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+	authenticate(c, userID, sessionID)
+}
 
-		// check if the token expired
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.AbortWithStatus(http.StatusUnauthorized)
-		}
+// authenticate sets the principal for a session and continues, or aborts when the session is no
+// longer usable.
+func authenticate(c *gin.Context, userID primitive.ObjectID, sessionID primitive.ObjectID) {
+	// check if the session was revoked, e.g. by logging out
+	if _, err := helper.GetActiveSession(sessionID, userID); err != nil {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
 
-		// check if the attached user exists
-		userCollection := database.OpenCollection(database.Client, "user-collection")
-		var user models.User
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		err = userCollection.FindOne(ctx, bson.M{"email": claims["sub"]}).Decode(&user)
-		if err != nil {
-			c.AbortWithStatus(http.StatusUnauthorized)
-		}
+	// check if the attached user exists
+	user, err := helper.GetUserById(userID)
+	if err != nil {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
 
-		// set the user
-		c.Set("user", user)
+	// suspended and banned users keep their sessions shut until they are reinstated
+	if helper.AccountRestricted(user) {
+		c.AbortWithStatusJSON(http.StatusForbidden, helper.AccountRestriction(user))
+		return
+	}
 
-		// continue
-		c.Next()
+	// set the principal
+	c.Set(helper.PrincipalKey, helper.NewPrincipal(user, sessionID))
 
-	} else {
-		c.AbortWithStatus(http.StatusUnauthorized)
-	}
+	// continue
+	c.Next()
 }
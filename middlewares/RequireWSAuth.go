@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+// RequireWSAuth authenticates a websocket upgrade. Browsers cannot set headers on /ws, so they
+// pass a single-use ticket from POST /ws/ticket in the query; other clients may use RequireAuth's
+// cookie or bearer token.
+func RequireWSAuth(c *gin.Context) {
+	ticket := c.Query("ticket")
+	if ticket == "" {
+		RequireAuth(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// deleting while reading makes the ticket single-use
+	filter := bson.M{
+		"ticketHash": helper.HashToken(ticket),
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	}
+	wsTicketCollection := database.OpenCollection(database.Client, "ws-ticket-collection")
+
+	var wsTicket models.WSTicket
+	if err := wsTicketCollection.FindOneAndDelete(ctx, filter).Decode(&wsTicket); err != nil {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	authenticate(c, wsTicket.UserID, wsTicket.SessionID)
+}
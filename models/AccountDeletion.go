@@ -0,0 +1,32 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	AccountDeletionScheduled = "scheduled"
+	AccountDeletionRunning   = "running"
+	AccountDeletionDone      = "done"
+	AccountDeletionCancelled = "cancelled"
+)
+
+// AccountDeletion is a request to delete an account once its grace period is over. The worker
+// records every finished step, so a deletion interrupted by a crash resumes where it stopped.
+type AccountDeletion struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	UserID         primitive.ObjectID `json:"userId" bson:"userId"`
+	Email          string             `json:"-" bson:"email"`
+	Name           string             `json:"-" bson:"name"`
+	Status         string             `json:"status" bson:"status"`
+	CompletedSteps []string           `json:"completedSteps" bson:"completedSteps"`
+	ItemsRemoved   int64              `json:"itemsRemoved" bson:"itemsRemoved"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	LastError      string             `json:"lastError" bson:"lastError"`
+	RequestedAt    time.Time          `json:"requestedAt" bson:"requestedAt"`
+	ScheduledFor   time.Time          `json:"scheduledFor" bson:"scheduledFor"`
+	StartedAt      time.Time          `json:"startedAt" bson:"startedAt"`
+	CompletedAt    time.Time          `json:"completedAt" bson:"completedAt"`
+	LockedUntil    time.Time          `json:"lockedUntil" bson:"lockedUntil"`
+}
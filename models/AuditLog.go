@@ -0,0 +1,20 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// AuditLog records an action taken by a moderator or admin.
+type AuditLog struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	ActorID    primitive.ObjectID `json:"actorId" bson:"actorId"`
+	ActorEmail string             `json:"actorEmail" bson:"actorEmail"`
+	Action     string             `json:"action" bson:"action"`
+	TargetType string             `json:"targetType" bson:"targetType"`
+	TargetID   primitive.ObjectID `json:"targetId" bson:"targetId"`
+	Details    bson.M             `json:"details" bson:"details"`
+	IP         string             `json:"ip" bson:"ip"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
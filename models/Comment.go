@@ -6,8 +6,9 @@ import (
 )
 
 type Comment struct {
-	ID          primitive.ObjectID `json:"_id" bson:"_id"`
-	CommenterID primitive.ObjectID `json:"commenter_id" bson:"commenter_id"`
-	CommentText string             `json:"comment_text" bson:"comment_text"`
-	CommentedAt time.Time          `json:"commented_at" bson:"commented_at"`
+	ID          primitive.ObjectID   `json:"_id" bson:"_id"`
+	CommenterID primitive.ObjectID   `json:"commenter_id" bson:"commenter_id"`
+	CommentText string               `json:"comment_text" bson:"comment_text"`
+	CommentedAt time.Time            `json:"commented_at" bson:"commented_at"`
+	Mentions    []primitive.ObjectID `json:"mentions" bson:"mentions"`
 }
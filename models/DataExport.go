@@ -0,0 +1,30 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	DataExportPending  = "pending"
+	DataExportBuilding = "building"
+	DataExportReady    = "ready"
+	DataExportFailed   = "failed"
+	DataExportExpired  = "expired"
+)
+
+// DataExport is a ZIP archive of everything stored about a user, built in the background and kept
+// in the exports GridFS bucket until it expires.
+type DataExport struct {
+	ID          primitive.ObjectID `json:"_id" bson:"_id"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	Status      string             `json:"status" bson:"status"`
+	FileID      primitive.ObjectID `json:"-" bson:"fileId"`
+	Size        int64              `json:"size" bson:"size"`
+	Attempts    int                `json:"attempts" bson:"attempts"`
+	LastError   string             `json:"-" bson:"lastError"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	CompletedAt time.Time          `json:"completedAt" bson:"completedAt"`
+	ExpiresAt   time.Time          `json:"expiresAt" bson:"expiresAt"`
+	LockedUntil time.Time          `json:"-" bson:"lockedUntil"`
+}
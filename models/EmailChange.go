@@ -0,0 +1,27 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	EmailChangeStatusPending   = "pending"
+	EmailChangeStatusCompleted = "completed"
+	EmailChangeStatusReverted  = "reverted"
+)
+
+type EmailChange struct {
+	ID              primitive.ObjectID `json:"_id" bson:"_id"`
+	UserID          primitive.ObjectID `json:"userId" bson:"userId"`
+	OldEmail        string             `json:"oldEmail" bson:"oldEmail"`
+	NewEmail        string             `json:"newEmail" bson:"newEmail"`
+	CodeHash        string             `json:"-" bson:"codeHash"`
+	Attempts        int                `json:"-" bson:"attempts"`
+	RevertTokenHash string             `json:"-" bson:"revertTokenHash"`
+	Status          string             `json:"status" bson:"status"`
+	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt       time.Time          `json:"expiresAt" bson:"expiresAt"`
+	CompletedAt     time.Time          `json:"completedAt" bson:"completedAt"`
+	RevertExpiresAt time.Time          `json:"revertExpiresAt" bson:"revertExpiresAt"`
+}
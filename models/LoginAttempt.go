@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for one key, either "account:<email>" or "ip:<address>".
+type LoginAttempt struct {
+	Key           string    `json:"key" bson:"_id"`
+	Failures      int       `json:"failures" bson:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt" bson:"lastFailureAt"`
+	LockedUntil   time.Time `json:"lockedUntil" bson:"lockedUntil"`
+}
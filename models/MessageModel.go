@@ -5,10 +5,18 @@ import (
 	"time"
 )
 
+// MessageParticipant is the copy of a sender or recipient kept in a message, only what chats
+// address people by and never the rest of their account.
+type MessageParticipant struct {
+	ID     primitive.ObjectID `json:"_id" bson:"_id"`
+	Handle string             `json:"handle" bson:"handle"`
+	Email  string             `json:"email" bson:"email"`
+}
+
 type Message struct {
 	ID        primitive.ObjectID `json:"_id" bson:"_id"`
-	Sender    User               `json:"sender" bson:"sender"`
-	Recipient User               `json:"recipient" bson:"recipient"`
+	Sender    MessageParticipant `json:"sender" bson:"sender"`
+	Recipient MessageParticipant `json:"recipient" bson:"recipient"`
 	Content   string             `json:"content" bson:"content"`
 	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
 	Status    string             `json:"status" bson:"status"`
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// MutedUser is an account whose posts, comments and signals a user no longer wants to see. A zero
+// Until mutes them until they are unmuted.
+type MutedUser struct {
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	Until     time.Time          `json:"until" bson:"until"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// MutedWord is a word or phrase that hides the posts and comments containing it.
+type MutedWord struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	Phrase    string             `json:"phrase" bson:"phrase"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// ExternalIdentity links a user to an account at an OpenID Connect provider.
+type ExternalIdentity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email" bson:"email"`
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
+}
+
+// OIDCLogin keeps the state, nonce and PKCE verifier of a login between the redirect to the
+// provider and its callback.
+type OIDCLogin struct {
+	ID           primitive.ObjectID `json:"_id" bson:"_id"`
+	StateHash    string             `json:"-" bson:"stateHash"`
+	Provider     string             `json:"provider" bson:"provider"`
+	Nonce        string             `json:"-" bson:"nonce"`
+	CodeVerifier string             `json:"-" bson:"codeVerifier"`
+	ExpiresAt    time.Time          `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSending = "sending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead"
+)
+
+type OutboxMessage struct {
+	ID            primitive.ObjectID `json:"_id" bson:"_id"`
+	To            string             `json:"to" bson:"to"`
+	Subject       string             `json:"subject" bson:"subject"`
+	Body          string             `json:"body,omitempty" bson:"body"`
+	Status        string             `json:"status" bson:"status"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	LastError     string             `json:"lastError" bson:"lastError"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	NextAttemptAt time.Time          `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LockedUntil   time.Time          `json:"lockedUntil" bson:"lockedUntil"`
+	SentAt        time.Time          `json:"sentAt" bson:"sentAt"`
+	// ExpiresAt is only set on dead messages, which are removed once it has passed.
+	ExpiresAt time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Passkey struct {
+	ID           primitive.ObjectID  `json:"_id" bson:"_id"`
+	UserID       primitive.ObjectID  `json:"userId" bson:"userId"`
+	Name         string              `json:"name" bson:"name"`
+	CredentialID []byte              `json:"-" bson:"credentialId"`
+	Credential   webauthn.Credential `json:"-" bson:"credential"`
+	CreatedAt    time.Time           `json:"createdAt" bson:"createdAt"`
+	LastUsedAt   time.Time           `json:"lastUsedAt" bson:"lastUsedAt"`
+}
+
+// WebAuthnCeremony keeps the challenge of a registration or login between its begin and finish
+// requests. A registration also records the session that started it and when it re-authenticated.
+type WebAuthnCeremony struct {
+	ID                primitive.ObjectID   `json:"_id" bson:"_id"`
+	UserID            primitive.ObjectID   `json:"userId" bson:"userId"`
+	Purpose           string               `json:"purpose" bson:"purpose"`
+	Session           webauthn.SessionData `json:"-" bson:"session"`
+	SessionID         primitive.ObjectID   `json:"-" bson:"sessionId,omitempty"`
+	ReauthenticatedAt time.Time            `json:"-" bson:"reauthenticatedAt,omitempty"`
+	ExpiresAt         time.Time            `json:"expiresAt" bson:"expiresAt"`
+}
@@ -13,4 +13,5 @@ type Post struct {
 	LikedBy   []primitive.ObjectID `json:"likedBy" bson:"likedBy"`
 	Images    []string             `json:"images" bson:"images"`
 	Comments  []Comment            `json:"comments" bson:"comments"`
+	Mentions  []primitive.ObjectID `json:"mentions" bson:"mentions"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Session struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	RevokedAt time.Time          `json:"revokedAt" bson:"revokedAt"`
+	UserAgent string             `json:"userAgent" bson:"userAgent"`
+	IP        string             `json:"ip" bson:"ip"`
+}
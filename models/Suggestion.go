@@ -0,0 +1,23 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Suggestion is an account a user might want to follow because people they follow do.
+type Suggestion struct {
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	MutualCount int                `json:"mutualCount" bson:"mutualCount"`
+	// a few of the accounts the user follows that follow the suggested one
+	MutualIDs []primitive.ObjectID `json:"mutualIds" bson:"mutualIds"`
+	Score     float64              `json:"score" bson:"score"`
+}
+
+// SuggestionSet holds the suggestions computed for a user, best first.
+type SuggestionSet struct {
+	ID          primitive.ObjectID `json:"_id" bson:"_id"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	Suggestions []Suggestion       `json:"suggestions" bson:"suggestions"`
+	ComputedAt  time.Time          `json:"computedAt" bson:"computedAt"`
+}
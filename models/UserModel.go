@@ -5,17 +5,52 @@ import (
 	"time"
 )
 
+// Account statuses set by moderators; accounts without a status are active.
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusBanned    = "banned"
+	// set while the data of a deleted account is being removed
+	UserStatusDeleted = "deleted"
+)
+
 type User struct {
-	ID               primitive.ObjectID   `json:"_id" bson:"_id"`
-	Name             string               `json:"name" bson:"name" validate:"required"`
-	Email            string               `json:"email" bson:"email" validate:"required"`
-	Password         string               `json:"password" bson:"password" validate:"required"`
-	CreatedAt        time.Time            `json:"createdAt" bson:"createdAt"`
-	Dp               string               `json:"dp" bson:"dp"`
-	Followers        []primitive.ObjectID `json:"followers" bson:"followers"`
-	Following        []primitive.ObjectID `json:"following" bson:"following"`
-	RequestsReceived []FollowRequest      `json:"requestsReceived" bson:"requestsReceived"`
-	RequestsSent     []FollowRequest      `json:"requestsSent" bson:"requestsSent"`
-	LastActive       time.Time            `json:"lastActive" bson:"lastActive"`
-	IsActive         bool                 `json:"isActive" bson:"isActive"`
+	ID                primitive.ObjectID   `json:"_id" bson:"_id"`
+	Name              string               `json:"name" bson:"name" validate:"required"`
+	Email             string               `json:"email" bson:"email" validate:"required"`
+	Handle            string               `json:"handle" bson:"handle,omitempty"`
+	HandleLower       string               `json:"-" bson:"handleLower,omitempty"`
+	HandleChangedAt   time.Time            `json:"-" bson:"handleChangedAt"`
+	PreviousHandles   []string             `json:"-" bson:"previousHandles"`
+	SearchTerms       []string             `json:"-" bson:"searchTerms"`
+	Password          string               `json:"password" bson:"password" validate:"required"`
+	PasswordResetDue  bool                 `json:"-" bson:"passwordResetDue"`
+	CreatedAt         time.Time            `json:"createdAt" bson:"createdAt"`
+	Dp                string               `json:"dp" bson:"dp"`
+	AvatarID          string               `json:"-" bson:"avatarId"`
+	Bio               string               `json:"bio" bson:"bio"`
+	Website           string               `json:"website" bson:"website"`
+	Pronouns          string               `json:"pronouns" bson:"pronouns"`
+	IsPublic          bool                 `json:"isPublic" bson:"isPublic"`
+	Followers         []primitive.ObjectID `json:"followers" bson:"followers"`
+	Following         []primitive.ObjectID `json:"following" bson:"following"`
+	RequestsReceived  []FollowRequest      `json:"requestsReceived" bson:"requestsReceived"`
+	RequestsSent      []FollowRequest      `json:"requestsSent" bson:"requestsSent"`
+	Blocked           []primitive.ObjectID `json:"-" bson:"blocked"`
+	MutedUsers        []MutedUser          `json:"-" bson:"mutedUsers"`
+	MutedWords        []MutedWord          `json:"-" bson:"mutedWords"`
+	HiddenSuggestions []primitive.ObjectID `json:"-" bson:"hiddenSuggestions"`
+	LastActive        time.Time            `json:"lastActive" bson:"lastActive"`
+	IsActive          bool                 `json:"isActive" bson:"isActive"`
+	PreviousEmails    []string             `json:"-" bson:"previousEmails"`
+	TotpEnabled       bool                 `json:"totpEnabled" bson:"totpEnabled"`
+	TotpSecret        string               `json:"-" bson:"totpSecret"`
+	TotpPendingSecret string               `json:"-" bson:"totpPendingSecret"`
+	TotpLastStep      int64                `json:"-" bson:"totpLastStep"`
+	RecoveryCodes     []string             `json:"-" bson:"recoveryCodes"`
+	Identities        []ExternalIdentity   `json:"-" bson:"identities"`
+	Roles             []string             `json:"roles" bson:"roles"`
+	Status            string               `json:"status" bson:"status"`
+	StatusReason      string               `json:"-" bson:"statusReason"`
+	SuspendedUntil    time.Time            `json:"suspendedUntil" bson:"suspendedUntil"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type WSTicket struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	TicketHash string             `json:"-" bson:"ticketHash"`
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	SessionID  primitive.ObjectID `json:"sessionId" bson:"sessionId"`
+	ExpiresAt  time.Time          `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"socialhive/controllers"
+	"socialhive/middlewares"
+)
+
+func AccountRouter(incomingRoutes *gin.Engine) {
+	auth := incomingRoutes.Group("", middlewares.RequireAuth)
+
+	auth.PATCH("/me", controllers.UpdateProfile)
+	auth.PUT("/me/handle", controllers.ChangeHandle)
+	auth.PUT("/me/privacy", controllers.SetAccountPrivacy)
+	auth.PUT("/me/avatar", controllers.UploadAvatar)
+	auth.DELETE("/me/avatar", controllers.DeleteAvatar)
+	auth.DELETE("/me", controllers.DeleteAccount)
+	auth.GET("/me/deletion", controllers.GetAccountDeletion)
+	auth.POST("/me/deletion/cancel", controllers.CancelAccountDeletion)
+	auth.POST("/me/export", controllers.RequestDataExport)
+	auth.GET("/me/export/:export_id", controllers.GetDataExport)
+	auth.GET("/me/export/:export_id/download", controllers.DownloadDataExport)
+	auth.PUT("/me/password", controllers.ChangePassword)
+	auth.POST("/me/email", controllers.RequestEmailChange)
+	auth.POST("/me/email/verify", controllers.ConfirmEmailChange)
+	auth.POST("/me/2fa/totp/enroll", controllers.EnrollTotp)
+	auth.POST("/me/2fa/totp/confirm", controllers.ConfirmTotp)
+	auth.POST("/me/2fa/totp/disable", controllers.DisableTotp)
+	auth.POST("/me/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
+	auth.GET("/me/passkeys", controllers.GetPasskeys)
+	auth.POST("/me/passkeys/register/begin", controllers.BeginPasskeyRegistration)
+	auth.POST("/me/passkeys/register/finish", controllers.FinishPasskeyRegistration)
+	auth.DELETE("/me/passkeys/:passkey_id", controllers.DeletePasskey)
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"socialhive/controllers"
+	"socialhive/helper"
+	"socialhive/middlewares"
+)
+
+func AdminRouter(incomingRoutes *gin.Engine) {
+	auth := incomingRoutes.Group("", middlewares.RequireAuth)
+
+	// moderators handle users and content, admins additionally run the service and hand out roles
+	staff := auth.Group("/admin", middlewares.RequireRole(helper.RoleModerator, helper.RoleAdmin))
+	staff.GET("/users", controllers.ListUsers)
+	staff.GET("/users/:user_id", controllers.GetUserForAdmin)
+	staff.POST("/users/:user_id/suspend", controllers.SuspendUser)
+	staff.POST("/users/:user_id/reinstate", controllers.ReinstateUser)
+	staff.POST("/users/:user_id/logout", controllers.ForceLogoutUser)
+	staff.POST("/users/:user_id/unlock", controllers.UnlockUser)
+	staff.DELETE("/posts/:post_id", controllers.AdminDeletePost)
+	staff.DELETE("/posts/:post_id/comments/:comment_id", controllers.AdminDeleteComment)
+	staff.DELETE("/messages/:message_id", controllers.AdminDeleteMessage)
+
+	admin := auth.Group("/admin", middlewares.RequireRole(helper.RoleAdmin))
+	admin.POST("/users/:user_id/ban", controllers.BanUser)
+	admin.PUT("/users/:user_id/roles", controllers.SetUserRoles)
+	admin.GET("/audit-log", controllers.GetAuditLog)
+	admin.GET("/deletions", controllers.GetAccountDeletions)
+	admin.GET("/outbox", controllers.GetOutboxMessages)
+	admin.POST("/outbox/:message_id/retry", controllers.RetryOutboxMessage)
+}
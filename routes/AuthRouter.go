@@ -8,7 +8,18 @@ import (
 func AuthRouter(incomingRoutes *gin.Engine) {
 	incomingRoutes.POST("/signup", controllers.SignUp)
 	incomingRoutes.POST("/login", controllers.Login)
+	incomingRoutes.POST("/login/2fa", controllers.VerifyTwoFactorLogin)
+	incomingRoutes.POST("/login/passkey/begin", controllers.BeginPasskeyLogin)
+	incomingRoutes.POST("/login/passkey/finish", controllers.FinishPasskeyLogin)
+	incomingRoutes.POST("/login/magic", controllers.RequestMagicLink)
+	incomingRoutes.POST("/login/magic/consume", controllers.ConsumeMagicLink)
+	incomingRoutes.GET("/auth/oidc", controllers.GetOIDCProviders)
+	incomingRoutes.GET("/auth/oidc/:provider/login", controllers.BeginOIDCLogin)
+	incomingRoutes.GET("/auth/oidc/:provider/callback", controllers.FinishOIDCLogin)
 	incomingRoutes.POST("/logout", controllers.Logout)
 	incomingRoutes.POST("/createuser", controllers.CreateUserByOtp)
-	incomingRoutes.GET("/user/:id", controllers.GetUserById)
+	incomingRoutes.GET("/email/revert/:token", controllers.ShowEmailRevert)
+	incomingRoutes.POST("/email/revert", controllers.RevertEmailChange)
+	incomingRoutes.POST("/password/reset", controllers.ResetPassword)
+	incomingRoutes.GET("/.well-known/jwks.json", controllers.GetJWKS)
 }
@@ -7,7 +7,10 @@ import (
 )
 
 func ChatRouter(incomingRoutes *gin.Engine) {
-	incomingRoutes.Use(middlewares.RequireAuth)
+	// /ws authenticates on its own since browsers connect with a ticket instead of a header
 	s := controllers.NewServer()
-	incomingRoutes.GET("/ws", s.HandleWS)
+	incomingRoutes.GET("/ws", middlewares.RequireWSAuth, s.HandleWS)
+
+	auth := incomingRoutes.Group("", middlewares.RequireAuth)
+	auth.POST("/ws/ticket", controllers.IssueWSTicket)
 }
@@ -7,13 +7,24 @@ import (
 )
 
 func ConnectionRouter(incomingRoutes *gin.Engine) {
-	incomingRoutes.Use(middlewares.RequireAuth)
+	auth := incomingRoutes.Group("", middlewares.RequireAuth)
 
-	incomingRoutes.POST("/follow-request", controllers.SendFollowRequest)
-	incomingRoutes.PUT("/follow-request/:request_id/accept", controllers.AcceptFollowRequest)
-	incomingRoutes.DELETE("/follow-request/receiver/:request_id", controllers.DeleteFollowRequestByReceiver)
-	incomingRoutes.DELETE("/follow-request/sender/:request_id", controllers.DeleteFollowRequestBySender)
-	incomingRoutes.GET("/followers/:user_id", controllers.GetAllFollowers)
-	incomingRoutes.GET("/following/:user_id", controllers.GetAllFollowing)
-	incomingRoutes.DELETE("/unfollow/:user_id", controllers.UnFollow)
+	auth.POST("/follow-request", controllers.SendFollowRequest)
+	auth.PUT("/follow-request/:request_id/accept", controllers.AcceptFollowRequest)
+	auth.DELETE("/follow-request/receiver/:request_id", controllers.DeleteFollowRequestByReceiver)
+	auth.DELETE("/follow-request/sender/:request_id", controllers.DeleteFollowRequestBySender)
+	auth.GET("/followers/:user_id", controllers.GetAllFollowers)
+	auth.GET("/following/:user_id", controllers.GetAllFollowing)
+	auth.DELETE("/unfollow/:user_id", controllers.UnFollow)
+	auth.GET("/blocks", controllers.GetBlockedUsers)
+	auth.POST("/block/:user_id", controllers.BlockUser)
+	auth.DELETE("/block/:user_id", controllers.UnblockUser)
+	auth.GET("/mutes", controllers.GetMutes)
+	auth.POST("/mute/:user_id", controllers.MuteUser)
+	auth.DELETE("/mute/:user_id", controllers.UnmuteUser)
+	auth.POST("/mutes/words", controllers.AddMutedWord)
+	auth.DELETE("/mutes/words/:word_id", controllers.DeleteMutedWord)
+	auth.GET("/relationship/:user_id", controllers.GetRelationship)
+	auth.GET("/suggestions", controllers.GetSuggestions)
+	auth.POST("/suggestions/:user_id/dismiss", controllers.DismissSuggestion)
 }
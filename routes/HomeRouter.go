@@ -7,6 +7,9 @@ import (
 )
 
 func HomeRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.Use(middlewares.RequireAuth)
-	incomingRoutes.GET("/validate", controllers.ValidateUser)
+	auth := incomingRoutes.Group("", middlewares.RequireAuth)
+	auth.GET("/validate", controllers.ValidateUser)
+	auth.GET("/user/:id", controllers.GetUserById)
+	auth.GET("/u/:handle", controllers.GetUserByHandle)
+	auth.GET("/search/users", controllers.SearchUsers)
 }
@@ -7,9 +7,9 @@ import (
 )
 
 func MessageRouter(incomingRoutes *gin.Engine) {
-	incomingRoutes.Use(middlewares.RequireAuth)
+	auth := incomingRoutes.Group("", middlewares.RequireAuth)
 
-	incomingRoutes.GET("/users", controllers.GetAllUsers)
-	incomingRoutes.GET("/messages/:user1/:user2", controllers.GetMessagesByUsers)
-	incomingRoutes.DELETE("/delete_message/:message_id", controllers.DeleteMessage)
+	auth.GET("/users", controllers.GetAllUsers)
+	auth.GET("/messages/:user1/:user2", controllers.GetMessagesByUsers)
+	auth.DELETE("/delete_message/:message_id", controllers.DeleteMessage)
 }
@@ -7,12 +7,12 @@ import (
 )
 
 func PostRouter(incomingRoutes *gin.Engine) {
-	incomingRoutes.Use(middlewares.RequireAuth)
+	auth := incomingRoutes.Group("", middlewares.RequireAuth)
 
-	incomingRoutes.POST("/create_post", controllers.CreatePost)
-	incomingRoutes.GET("/posts/:user_id", controllers.GetPostsByUserId)
-	incomingRoutes.GET("images/:image_id", controllers.GetImage)
-	incomingRoutes.GET("/update_likes/:action/:post_id/:user_id", controllers.UpdateLikes)
-	incomingRoutes.POST("/add_comment", controllers.AddComment)
-	incomingRoutes.GET("feeds/:user_id", controllers.GetUserFeedsByID)
+	auth.POST("/create_post", controllers.CreatePost)
+	auth.GET("/posts/:user_id", controllers.GetPostsByUserId)
+	auth.GET("images/:image_id", controllers.GetImage)
+	auth.GET("/update_likes/:action/:post_id", controllers.UpdateLikes)
+	auth.POST("/add_comment", controllers.AddComment)
+	auth.GET("feeds/:user_id", controllers.GetUserFeedsByID)
 }
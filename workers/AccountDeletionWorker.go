@@ -0,0 +1,347 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"slices"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+const (
+	accountDeletionPollInterval = time.Minute
+	// long enough for one step; a worker that dies mid-step leaves it to the next one after this
+	accountDeletionLockDuration = 10 * time.Minute
+	accountDeletionBatchSize    = 100
+)
+
+var accountDeletionCollection *mongo.Collection = database.OpenCollection(database.Client, "account-deletion-collection")
+
+// deletionStep removes one kind of data of a user and returns how many items it touched. Steps
+// must be safe to run again, since a crash can interrupt them halfway.
+type deletionStep struct {
+	name string
+	run  func(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+// accountDeletionSteps run in order. The user document goes last, so that an interrupted deletion
+// can still be found and resumed.
+var accountDeletionSteps = []deletionStep{
+	{"lock", lockDeletedAccount},
+	{"posts", deletePosts},
+	{"avatar", deleteAvatar},
+	{"comments", deleteComments},
+	{"likes", deleteLikes},
+	{"messages", deleteMessages},
+	{"follow-graph", scrubFollowGraph},
+	{"exports", deleteDataExports},
+	{"suggestions", deleteSuggestions},
+	{"user", deleteUserDocument},
+}
+
+// RunAccountDeletionWorker deletes accounts whose grace period is over until the process exits.
+func RunAccountDeletionWorker() {
+	ticker := time.NewTicker(accountDeletionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for processNextAccountDeletion() {
+		}
+		<-ticker.C
+	}
+}
+
+// processNextAccountDeletion claims one due deletion and runs its remaining steps. It reports
+// whether a deletion was claimed so the caller can work through the backlog before sleeping.
+func processNextAccountDeletion() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	// deletions left "running" by a crashed worker are picked up again once their lock expires
+	filter := bson.M{
+		"$or": []bson.M{
+			{"status": models.AccountDeletionScheduled, "scheduledFor": bson.M{"$lte": now}},
+			{"status": models.AccountDeletionRunning, "lockedUntil": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      models.AccountDeletionRunning,
+			"lockedUntil": now.Add(accountDeletionLockDuration),
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"scheduledFor": 1}).
+		SetReturnDocument(options.After)
+
+	var deletion models.AccountDeletion
+	err := accountDeletionCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&deletion)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Println("account deletion: failed to claim deletion:", err)
+		}
+		return false
+	}
+
+	if deletion.StartedAt.IsZero() {
+		recordDeletionProgress(deletion.ID, bson.M{"$set": bson.M{"startedAt": now}})
+	}
+
+	for _, step := range accountDeletionSteps {
+		if slices.Contains(deletion.CompletedSteps, step.name) {
+			continue
+		}
+
+		stepCtx, stepCancel := context.WithTimeout(context.Background(), accountDeletionLockDuration)
+		removed, err := step.run(stepCtx, deletion.UserID)
+		stepCancel()
+		if err != nil {
+			// the lock is left to expire, which retries the step later
+			log.Printf("account deletion: step %s for %s failed: %v", step.name, deletion.UserID.Hex(), err)
+			recordDeletionProgress(deletion.ID, bson.M{"$set": bson.M{"lastError": step.name + ": " + err.Error()}})
+			return true
+		}
+
+		recordDeletionProgress(deletion.ID, bson.M{
+			"$addToSet": bson.M{"completedSteps": step.name},
+			"$inc":      bson.M{"itemsRemoved": removed},
+			"$set":      bson.M{"lockedUntil": time.Now().Add(accountDeletionLockDuration)},
+		})
+	}
+
+	body := helper.RenderEmail("Your account was deleted", deletion.Name, []string{
+		"Your SocialHive account and everything in it has been deleted. We're sorry to see you go.",
+	}, "", "")
+	if err := helper.QueueEmail(deletion.Email, "Your SocialHive account was deleted", body); err != nil {
+		log.Println("account deletion: failed to queue confirmation:", err)
+	}
+
+	// the record stays as proof of the deletion, without the personal details
+	recordDeletionProgress(deletion.ID, bson.M{
+		"$set": bson.M{
+			"status":      models.AccountDeletionDone,
+			"completedAt": time.Now(),
+			"lastError":   "",
+		},
+		"$unset": bson.M{"email": "", "name": ""},
+	})
+	return true
+}
+
+func recordDeletionProgress(deletionID primitive.ObjectID, update bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := accountDeletionCollection.UpdateOne(ctx, bson.M{"_id": deletionID}, update); err != nil {
+		log.Println("account deletion: failed to record progress:", err)
+	}
+}
+
+// lockDeletedAccount shuts the account and removes everything that could be used to log in to it.
+func lockDeletedAccount(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return 0, err
+	}
+
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"status": models.UserStatusDeleted}}); err != nil {
+		return 0, err
+	}
+	if err := helper.RevokeSessions(userID, nil); err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, name := range []string{"session-collection", "passkey-collection", "magic-link-collection", "email-change-collection", "password-reset-collection"} {
+		result, err := database.OpenCollection(database.Client, name).DeleteMany(ctx, bson.M{"userId": userID})
+		if err != nil {
+			return removed, err
+		}
+		removed += result.DeletedCount
+	}
+	return removed, helper.ClearLoginFailures(user.Email)
+}
+
+// deletePosts removes the user's posts along with their images, a batch at a time.
+func deletePosts(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	postCollection := database.OpenCollection(database.Client, "posts-collection")
+
+	var removed int64
+	for {
+		cursor, err := postCollection.Find(ctx, bson.M{"uploader": userID}, options.Find().SetLimit(accountDeletionBatchSize))
+		if err != nil {
+			return removed, err
+		}
+		var posts []models.Post
+		if err := cursor.All(ctx, &posts); err != nil {
+			return removed, err
+		}
+		if len(posts) == 0 {
+			return removed, nil
+		}
+
+		for _, post := range posts {
+			// images go first, a post deleted before its images would leave them unreachable
+			for _, image := range post.Images {
+				if err := deleteGridFSFile(image); err != nil {
+					return removed, err
+				}
+			}
+			if _, err := postCollection.DeleteOne(ctx, bson.M{"_id": post.ID}); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+}
+
+// deleteGridFSFile removes a stored file by its hex id. Files that are already gone are fine.
+func deleteGridFSFile(hexID string) error {
+	fileID, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return nil
+	}
+	if err := database.GridFSBucket.Delete(fileID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+func deleteAvatar(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return 0, err
+	}
+	if user.AvatarID == "" {
+		return 0, nil
+	}
+	if err := deleteGridFSFile(user.AvatarID); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func deleteComments(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	postCollection := database.OpenCollection(database.Client, "posts-collection")
+	result, err := postCollection.UpdateMany(ctx,
+		bson.M{"comments.commenter_id": userID},
+		bson.M{"$pull": bson.M{"comments": bson.M{"commenter_id": userID}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func deleteLikes(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	postCollection := database.OpenCollection(database.Client, "posts-collection")
+	result, err := postCollection.UpdateMany(ctx, bson.M{"likedBy": userID}, bson.M{"$pull": bson.M{"likedBy": userID}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// deleteMessages removes what the user sent. Messages they received belong to the other person's
+// history, so they are kept with the recipient reduced to a placeholder.
+func deleteMessages(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	msgCollection := database.OpenCollection(database.Client, "message-collection")
+
+	deleted, err := msgCollection.DeleteMany(ctx, bson.M{"sender._id": userID})
+	if err != nil {
+		return 0, err
+	}
+
+	placeholder := models.MessageParticipant{ID: userID}
+	anonymised, err := msgCollection.UpdateMany(ctx,
+		bson.M{"recipient._id": userID, "$or": []bson.M{{"recipient.email": bson.M{"$ne": ""}}, {"recipient.handle": bson.M{"$ne": ""}}}},
+		bson.M{"$set": bson.M{"recipient": placeholder}},
+	)
+	if err != nil {
+		return deleted.DeletedCount, err
+	}
+	return deleted.DeletedCount + anonymised.ModifiedCount, nil
+}
+
+// scrubFollowGraph removes the user from the followers, following, follow requests, block lists,
+// mute lists and dismissed suggestions of others.
+func scrubFollowGraph(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	filter := bson.M{
+		"_id": bson.M{"$ne": userID},
+		"$or": []bson.M{
+			{"followers": userID},
+			{"following": userID},
+			{"requestsReceived.from": userID},
+			{"requestsSent.to": userID},
+			{"blocked": userID},
+			{"mutedUsers.userId": userID},
+			{"hiddenSuggestions": userID},
+		},
+	}
+	update := bson.M{"$pull": bson.M{
+		"followers":         userID,
+		"following":         userID,
+		"requestsReceived":  bson.M{"from": userID},
+		"requestsSent":      bson.M{"to": userID},
+		"blocked":           userID,
+		"mutedUsers":        bson.M{"userId": userID},
+		"hiddenSuggestions": userID,
+	}}
+	result, err := userCollection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func deleteSuggestions(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := suggestionCollection.DeleteMany(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func deleteDataExports(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	cursor, err := dataExportCollection.Find(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return 0, err
+	}
+	var exports []models.DataExport
+	if err := cursor.All(ctx, &exports); err != nil {
+		return 0, err
+	}
+
+	for _, export := range exports {
+		if err := deleteExportFile(export.FileID); err != nil {
+			return 0, err
+		}
+	}
+	result, err := dataExportCollection.DeleteMany(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func deleteUserDocument(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	result, err := userCollection.DeleteOne(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
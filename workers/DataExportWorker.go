@@ -0,0 +1,407 @@
+package workers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"log"
+	"os"
+	"path"
+	"socialhive/database"
+	"socialhive/dto"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+const (
+	dataExportPollInterval = 30 * time.Second
+	dataExportLockDuration = 30 * time.Minute
+	dataExportMaxAttempts  = 3
+)
+
+var dataExportCollection *mongo.Collection = database.OpenCollection(database.Client, "data-export-collection")
+
+// RunDataExportWorker builds requested data exports and removes expired ones until the process
+// exits.
+func RunDataExportWorker() {
+	ticker := time.NewTicker(dataExportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for buildNextDataExport() {
+		}
+		expireDataExports()
+		<-ticker.C
+	}
+}
+
+// buildNextDataExport claims one requested export and builds its archive. It reports whether an
+// export was claimed.
+func buildNextDataExport() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"$or": []bson.M{
+			{"status": models.DataExportPending},
+			{"status": models.DataExportBuilding, "lockedUntil": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      models.DataExportBuilding,
+			"lockedUntil": now.Add(dataExportLockDuration),
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"createdAt": 1}).
+		SetReturnDocument(options.After)
+
+	var export models.DataExport
+	err := dataExportCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&export)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Println("data export: failed to claim export:", err)
+		}
+		return false
+	}
+
+	user, err := helper.GetUserById(export.UserID)
+	if err != nil {
+		finishDataExport(export.ID, bson.M{"status": models.DataExportFailed, "lastError": err.Error()})
+		return true
+	}
+
+	// every attempt writes to the same file, recorded before the first one starts, so that what a
+	// failed or interrupted attempt left behind is deleted before the next one and never orphaned
+	fileID := export.FileID
+	if fileID.IsZero() {
+		fileID = primitive.NewObjectID()
+		if _, err := dataExportCollection.UpdateOne(ctx, bson.M{"_id": export.ID}, bson.M{"$set": bson.M{"fileId": fileID}}); err != nil {
+			log.Println("data export: failed to record archive:", err)
+			return true
+		}
+	} else if err := deleteExportFile(fileID); err != nil {
+		log.Println("data export: failed to delete earlier archive:", err)
+		return true
+	}
+
+	buildCtx, buildCancel := context.WithTimeout(context.Background(), dataExportLockDuration)
+	defer buildCancel()
+
+	size, err := writeDataExport(buildCtx, fileID, user)
+	if err != nil {
+		log.Printf("data export: building %s failed (attempt %d): %v", export.ID.Hex(), export.Attempts, err)
+		status := models.DataExportPending
+		if export.Attempts >= dataExportMaxAttempts {
+			status = models.DataExportFailed
+			if err := deleteExportFile(fileID); err != nil {
+				log.Println("data export: failed to delete archive:", err)
+			}
+		}
+		finishDataExport(export.ID, bson.M{"status": status, "lastError": err.Error()})
+		return true
+	}
+
+	expiresAt := time.Now().Add(helper.GetEnvDuration("DATA_EXPORT_TTL", 72*time.Hour))
+	finishDataExport(export.ID, bson.M{
+		"status":      models.DataExportReady,
+		"fileId":      fileID,
+		"size":        size,
+		"completedAt": time.Now(),
+		"expiresAt":   expiresAt,
+		"lastError":   "",
+	})
+
+	link := os.Getenv("HOST_NAME") + "me/export/" + export.ID.Hex() + "/download"
+	body := helper.RenderEmail("Your data export is ready", user.Name, []string{
+		"The copy of your SocialHive data you asked for is ready. Log in and use the button below to download it.",
+		"The download is available until " + expiresAt.UTC().Format(time.RFC1123) + ".",
+	}, "Download", link)
+	if err := helper.QueueEmail(user.Email, "Your SocialHive data export is ready", body); err != nil {
+		log.Println("data export: failed to queue notification:", err)
+	}
+	return true
+}
+
+func finishDataExport(exportID primitive.ObjectID, set bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := dataExportCollection.UpdateOne(ctx, bson.M{"_id": exportID}, bson.M{"$set": set}); err != nil {
+		log.Println("data export: failed to record result:", err)
+	}
+}
+
+// expireDataExports deletes the archives of exports past their expiry.
+func expireDataExports() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := dataExportCollection.Find(ctx, bson.M{"status": models.DataExportReady, "expiresAt": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		log.Println("data export: failed to find expired exports:", err)
+		return
+	}
+	var exports []models.DataExport
+	if err := cursor.All(ctx, &exports); err != nil {
+		log.Println("data export: failed to find expired exports:", err)
+		return
+	}
+
+	for _, export := range exports {
+		if err := deleteExportFile(export.FileID); err != nil {
+			log.Println("data export: failed to delete archive:", err)
+			continue
+		}
+		finishDataExport(export.ID, bson.M{"status": models.DataExportExpired})
+	}
+}
+
+func deleteExportFile(fileID primitive.ObjectID) error {
+	if fileID.IsZero() {
+		return nil
+	}
+	if err := database.ExportBucket.Delete(fileID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it, which gives the size of the archive.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// writeDataExport streams the archive of a user straight into GridFS and returns its size.
+func writeDataExport(ctx context.Context, fileID primitive.ObjectID, user models.User) (int64, error) {
+	upload, err := database.ExportBucket.OpenUploadStreamWithID(fileID, "export-"+user.ID.Hex()+".zip")
+	if err != nil {
+		return 0, err
+	}
+
+	counter := &countingWriter{w: upload}
+	archive := zip.NewWriter(counter)
+	if err := writeDataExportEntries(ctx, archive, user); err != nil {
+		upload.Abort()
+		return 0, err
+	}
+	if err := archive.Close(); err != nil {
+		upload.Abort()
+		return 0, err
+	}
+	if err := upload.Close(); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// exportedUser names another user without exposing more of their account than the profile does.
+type exportedUser struct {
+	ID     primitive.ObjectID `json:"_id"`
+	Name   string             `json:"name,omitempty"`
+	Handle string             `json:"handle"`
+}
+
+type exportedMessage struct {
+	ID        primitive.ObjectID `json:"_id"`
+	From      exportedUser       `json:"from"`
+	To        exportedUser       `json:"to"`
+	Content   string             `json:"content"`
+	Timestamp time.Time          `json:"timestamp"`
+	Status    string             `json:"status"`
+}
+
+type exportedConnections struct {
+	Followers        []exportedUser         `json:"followers"`
+	Following        []exportedUser         `json:"following"`
+	RequestsReceived []models.FollowRequest `json:"requestsReceived"`
+	RequestsSent     []models.FollowRequest `json:"requestsSent"`
+	Blocked          []exportedUser         `json:"blocked"`
+	Muted            []models.MutedUser     `json:"muted"`
+	MutedWords       []models.MutedWord     `json:"mutedWords"`
+}
+
+type exportedComment struct {
+	PostID  primitive.ObjectID `json:"postId"`
+	Comment models.Comment     `json:"comment"`
+}
+
+func writeDataExportEntries(ctx context.Context, archive *zip.Writer, user models.User) error {
+	postCollection := database.OpenCollection(database.Client, "posts-collection")
+	msgCollection := database.OpenCollection(database.Client, "message-collection")
+
+	// profile.json has what the user can see of their own account, never its secrets
+	if err := writeJSONEntry(archive, "profile.json", dto.NewSelfUser(user)); err != nil {
+		return err
+	}
+	if user.AvatarID != "" {
+		if err := writeImageEntry(archive, user.AvatarID); err != nil {
+			return err
+		}
+	}
+
+	cursor, err := postCollection.Find(ctx, bson.M{"uploader": user.ID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return err
+	}
+	posts := []models.Post{}
+	if err := cursor.All(ctx, &posts); err != nil {
+		return err
+	}
+	if err := writeJSONEntry(archive, "posts.json", posts); err != nil {
+		return err
+	}
+	for _, post := range posts {
+		for _, image := range post.Images {
+			if err := writeImageEntry(archive, image); err != nil {
+				return err
+			}
+		}
+	}
+
+	// comments live inside the posts they were made on
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"comments.commenter_id": user.ID}}},
+		{{Key: "$unwind", Value: "$comments"}},
+		{{Key: "$match", Value: bson.M{"comments.commenter_id": user.ID}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "postId": "$_id", "comment": "$comments"}}},
+	}
+	cursor, err = postCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var commentDocs []struct {
+		PostID  primitive.ObjectID `bson:"postId"`
+		Comment models.Comment     `bson:"comment"`
+	}
+	if err := cursor.All(ctx, &commentDocs); err != nil {
+		return err
+	}
+	comments := []exportedComment{}
+	for _, doc := range commentDocs {
+		comments = append(comments, exportedComment{PostID: doc.PostID, Comment: doc.Comment})
+	}
+	if err := writeJSONEntry(archive, "comments.json", comments); err != nil {
+		return err
+	}
+
+	filter := bson.M{"$or": []bson.M{{"sender._id": user.ID}, {"recipient._id": user.ID}}}
+	cursor, err = msgCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"timestamp": 1}))
+	if err != nil {
+		return err
+	}
+	var storedMessages []models.Message
+	if err := cursor.All(ctx, &storedMessages); err != nil {
+		return err
+	}
+	// the stored participants carry emails, which are the other person's to share
+	messages := []exportedMessage{}
+	for _, message := range storedMessages {
+		messages = append(messages, exportedMessage{
+			ID:        message.ID,
+			From:      exportedUser{ID: message.Sender.ID, Handle: message.Sender.Handle},
+			To:        exportedUser{ID: message.Recipient.ID, Handle: message.Recipient.Handle},
+			Content:   message.Content,
+			Timestamp: message.Timestamp,
+			Status:    message.Status,
+		})
+	}
+	if err := writeJSONEntry(archive, "messages.json", messages); err != nil {
+		return err
+	}
+
+	followers, err := exportedUsers(ctx, user.Followers)
+	if err != nil {
+		return err
+	}
+	following, err := exportedUsers(ctx, user.Following)
+	if err != nil {
+		return err
+	}
+	blocked, err := exportedUsers(ctx, user.Blocked)
+	if err != nil {
+		return err
+	}
+	graph := exportedConnections{
+		Followers:        followers,
+		Following:        following,
+		RequestsReceived: user.RequestsReceived,
+		RequestsSent:     user.RequestsSent,
+		Blocked:          blocked,
+		Muted:            user.MutedUsers,
+		MutedWords:       user.MutedWords,
+	}
+	return writeJSONEntry(archive, "connections.json", graph)
+}
+
+func exportedUsers(ctx context.Context, ids []primitive.ObjectID) ([]exportedUser, error) {
+	users := []exportedUser{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	opts := options.Find().SetProjection(bson.M{"name": 1, "handle": 1})
+	cursor, err := userCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var found []models.User
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	for _, user := range found {
+		users = append(users, exportedUser{ID: user.ID, Name: user.Name, Handle: user.Handle})
+	}
+	return users, nil
+}
+
+func writeJSONEntry(archive *zip.Writer, name string, value interface{}) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeImageEntry copies an uploaded image, as it was uploaded, into the images folder.
+func writeImageEntry(archive *zip.Writer, hexID string) error {
+	fileID, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return nil
+	}
+	file, err := database.GridFSBucket.OpenDownloadStream(fileID)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entry, err := archive.Create("images/" + hexID + path.Ext(file.GetFile().Name))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
@@ -0,0 +1,119 @@
+package workers
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"math/rand"
+	"socialhive/database"
+	"socialhive/helper"
+	"socialhive/models"
+	"time"
+)
+
+const (
+	outboxPollInterval = 5 * time.Second
+	outboxLockDuration = 2 * time.Minute
+	outboxBaseBackoff  = 30 * time.Second
+	outboxMaxBackoff   = 6 * time.Hour
+)
+
+var outboxCollection *mongo.Collection = database.OpenCollection(database.Client, "outbox")
+
+// RunOutboxWorker delivers queued emails until the process exits. Failed deliveries are retried
+// with exponential backoff and dead-lettered after OUTBOX_MAX_ATTEMPTS failures.
+func RunOutboxWorker() {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for deliverNextOutboxMessage() {
+		}
+		<-ticker.C
+	}
+}
+
+// deliverNextOutboxMessage claims one due message and tries to send it. It reports whether a
+// message was claimed so the caller can drain the queue before sleeping.
+func deliverNextOutboxMessage() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	// messages left in "sending" by a crashed worker become claimable again once their lock expires
+	filter := bson.M{
+		"$or": []bson.M{
+			{"status": models.OutboxStatusPending, "nextAttemptAt": bson.M{"$lte": now}},
+			{"status": models.OutboxStatusSending, "lockedUntil": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":      models.OutboxStatusSending,
+		"lockedUntil": now.Add(outboxLockDuration),
+	}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"nextAttemptAt": 1}).
+		SetReturnDocument(options.After)
+
+	var message models.OutboxMessage
+	err := outboxCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&message)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Println("outbox: failed to claim message:", err)
+		}
+		return false
+	}
+
+	sendErr := helper.SendEmail(message.To, message.Subject, message.Body)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if sendErr == nil {
+		// the body can hold a one-time code, so it is not kept once delivered
+		update = bson.M{
+			"$set": bson.M{
+				"status": models.OutboxStatusSent,
+				"sentAt": time.Now(),
+			},
+			"$unset": bson.M{"body": ""},
+		}
+	} else {
+		attempts := message.Attempts + 1
+		status := models.OutboxStatusPending
+		if attempts >= helper.GetEnvInt("OUTBOX_MAX_ATTEMPTS", 8) {
+			status = models.OutboxStatusDead
+		}
+		set := bson.M{
+			"status":        status,
+			"attempts":      attempts,
+			"lastError":     sendErr.Error(),
+			"nextAttemptAt": time.Now().Add(outboxBackoff(attempts)),
+		}
+		// dead letters stay around long enough to be retried, then expire along with their body
+		if status == models.OutboxStatusDead {
+			set["expiresAt"] = time.Now().Add(helper.GetEnvDuration("OUTBOX_DEAD_RETENTION", 7*24*time.Hour))
+		}
+		update = bson.M{"$set": set}
+		log.Printf("outbox: delivery of %s to %s failed (attempt %d): %v", message.ID.Hex(), message.To, attempts, sendErr)
+	}
+
+	if _, err := outboxCollection.UpdateOne(ctx, bson.M{"_id": message.ID}, update); err != nil {
+		log.Println("outbox: failed to record delivery result:", err)
+	}
+	return true
+}
+
+// outboxBackoff doubles the wait after every failed attempt, with up to 20% jitter so that a
+// burst of failures does not retry in lockstep.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxMaxBackoff
+	if attempts < 20 {
+		backoff = min(outboxBaseBackoff<<(attempts-1), outboxMaxBackoff)
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff)/5 + 1))
+	return backoff + jitter
+}
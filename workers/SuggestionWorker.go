@@ -0,0 +1,80 @@
+package workers
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"socialhive/database"
+	"socialhive/helper"
+	"time"
+)
+
+var suggestionCollection *mongo.Collection = database.OpenCollection(database.Client, "suggestion-collection")
+
+// RunSuggestionWorker precomputes follow suggestions for recently active users until the process
+// exits, so that opening the suggestions does not have to walk a large graph.
+func RunSuggestionWorker() {
+	interval := helper.GetEnvDuration("SUGGESTION_REFRESH_INTERVAL", 6*time.Hour)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshSuggestions(interval)
+		<-ticker.C
+	}
+}
+
+// refreshSuggestions recomputes the suggestions that are older than maxAge for the users active
+// within SUGGESTION_ACTIVE_WINDOW.
+func refreshSuggestions(maxAge time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	activeSince := time.Now().Add(-helper.GetEnvDuration("SUGGESTION_ACTIVE_WINDOW", 30*24*time.Hour))
+	filter := bson.M{"$and": []bson.M{
+		{"$or": []bson.M{{"isActive": true}, {"lastActive": bson.M{"$gte": activeSince}}}},
+		helper.UnrestrictedAccountFilter(),
+	}}
+	userCollection := database.OpenCollection(database.Client, "user-collection")
+	cursor, err := userCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		log.Println("suggestions: failed to list active users:", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	refreshed := 0
+	for cursor.Next(ctx) {
+		var entry struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&entry); err != nil {
+			log.Println("suggestions: failed to decode user:", err)
+			continue
+		}
+
+		fresh := bson.M{"userId": entry.ID, "computedAt": bson.M{"$gt": time.Now().Add(-maxAge)}}
+		if count, err := suggestionCollection.CountDocuments(ctx, fresh); err != nil || count > 0 {
+			continue
+		}
+
+		user, err := helper.GetUserById(entry.ID)
+		if err != nil {
+			continue
+		}
+		if _, err := helper.RefreshSuggestions(user); err != nil {
+			log.Println("suggestions: failed to refresh for", entry.ID.Hex()+":", err)
+			continue
+		}
+		refreshed++
+	}
+	if err := cursor.Err(); err != nil {
+		log.Println("suggestions: failed to walk users:", err)
+	}
+	if refreshed > 0 {
+		log.Println("suggestions: refreshed", refreshed, "users")
+	}
+}